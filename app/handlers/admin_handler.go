@@ -0,0 +1,580 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/driver"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/app/worker"
+	"gerbangapi/prisma/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AdminHandler struct {
+	DB     *db.PrismaClient
+	Pool   *worker.Pool
+	States *orderstate.Machine
+}
+
+func NewAdminHandler(dbClient *db.PrismaClient, pool *worker.Pool) *AdminHandler {
+	return &AdminHandler{DB: dbClient, Pool: pool, States: orderstate.New(dbClient)}
+}
+
+// adminActor adalah identitas admin di riwayat status order
+func adminActor(c echo.Context) string {
+	userID, _ := c.Get("user_id").(string)
+	return "admin:" + userID
+}
+
+// pageParams membaca query limit (default 50, maks 200) & offset
+func pageParams(c echo.Context) (int, int, error) {
+	limit := 50
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 200 {
+			return 0, 0, errors.New("limit harus 1 - 200")
+		}
+		limit = n
+	}
+	offset := 0
+	if raw := c.QueryParam("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset tidak valid")
+		}
+		offset = n
+	}
+	return limit, offset, nil
+}
+
+// ==========================================
+// 1. WORKER & QUEUE STATS
+// ==========================================
+func (h *AdminHandler) WorkerStats(c echo.Context) error {
+	stats := h.Pool.Stats(c.Request().Context())
+
+	totalDepth := int64(0)
+	totalActive := 0
+	for _, s := range stats {
+		totalDepth += s.QueueDepth
+		totalActive += s.ActiveWorkers
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Worker stats retrieved successfully",
+		"data": echo.Map{
+			"suppliers":      stats,
+			"queue_depth":    totalDepth,
+			"active_workers": totalActive,
+		},
+	})
+}
+
+// ==========================================
+// 2. UBAH STATUS ORDER (Override Admin)
+// ==========================================
+// Status supplier order diubah lewat state machine; internal order ikut disamakan.
+// Transisi yang tidak diizinkan (misal failed -> delivered) butuh "force": true.
+func (h *AdminHandler) UpdateOrderStatus(c echo.Context) error {
+	type Req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+		Force  bool   `json:"force"`
+	}
+
+	req := new(Req)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+	if req.Status == "" || req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "status dan reason required"})
+	}
+
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	order, err := h.DB.SupplierOrder.FindUnique(db.SupplierOrder.ID.Equals(id)).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier order tidak ditemukan"})
+	}
+
+	ok, err := h.States.SupplierOrder(ctx, orderstate.SupplierChange{
+		OrderID: order.ID,
+		From:    order.Status,
+		To:      req.Status,
+		Reason:  req.Reason,
+		Actor:   adminActor(c),
+		Force:   req.Force,
+	})
+	if errors.Is(err, orderstate.ErrIllegalTransition) || errors.Is(err, orderstate.ErrUnknownStatus) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Status order sudah berubah, silakan muat ulang"})
+	}
+
+	if _, err := h.States.InternalOrder(ctx, orderstate.InternalChange{
+		OrderID: order.InternalOrderID,
+		To:      req.Status,
+		Reason:  req.Reason,
+		Actor:   adminActor(c),
+		Force:   req.Force,
+	}); err != nil {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"error": "Supplier order diubah, tapi internal order tidak: " + err.Error(),
+		})
+	}
+
+	// Alasan dari admin bisa berisi detail internal, jadi seller hanya menerima pesan standar
+	h.Pool.NotifyOrderStatus(ctx, order.InternalOrderID, req.Status, "", false)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Status order diubah",
+		"data": echo.Map{
+			"supplier_order_id": order.ID,
+			"internal_order_id": order.InternalOrderID,
+			"from":              order.Status,
+			"to":                req.Status,
+		},
+	})
+}
+
+// ==========================================
+// 3. RIWAYAT STATUS ORDER
+// ==========================================
+func (h *AdminHandler) OrderStatusHistory(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	order, err := h.DB.SupplierOrder.FindUnique(db.SupplierOrder.ID.Equals(id)).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier order tidak ditemukan"})
+	}
+
+	supplierHistory, err := h.States.History(ctx, orderstate.TypeSupplierOrder, order.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	internalHistory, err := h.States.History(ctx, orderstate.TypeInternalOrder, order.InternalOrderID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Order status history retrieved successfully",
+		"data": echo.Map{
+			"supplier_order": supplierHistory,
+			"internal_order": internalHistory,
+		},
+	})
+}
+
+// ==========================================
+// 4. ARTEFAK KEGAGALAN SCRAPER
+// ==========================================
+// Screenshot, HTML halaman, dan Playwright trace (buka di trace.playwright.dev)
+// yang disimpan saat login / pembelian di supplier gagal.
+func (h *AdminHandler) OrderArtifacts(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	order, err := h.DB.SupplierOrder.FindUnique(db.SupplierOrder.ID.Equals(id)).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier order tidak ditemukan"})
+	}
+
+	artifacts, err := h.Pool.Drivers.Artifacts().List(order.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	latest, _ := order.ArtifactPath()
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Order artifacts retrieved successfully",
+		"data": echo.Map{
+			"latest":    latest,
+			"artifacts": artifacts,
+		},
+	})
+}
+
+func (h *AdminHandler) DownloadArtifact(c echo.Context) error {
+	path, err := h.Pool.Drivers.Artifacts().Open(c.Param("id"), c.Param("*"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	}
+	return c.Attachment(path, filepath.Base(path))
+}
+
+// ==========================================
+// 5. DAFTAR SUPPLIER ORDER (Antrian)
+// ==========================================
+// Filter opsional: status (boleh dipisah koma), supplier_id, older_than /
+// newer_than (umur order, format durasi Go: "30m", "2h"), limit (maks 200), offset.
+// Urut dari order paling lama agar antrian yang macet terlihat lebih dulu.
+func (h *AdminHandler) ListSupplierOrders(c echo.Context) error {
+	var where []db.SupplierOrderWhereParam
+
+	if status := c.QueryParam("status"); status != "" {
+		statuses := strings.Split(status, ",")
+		for _, s := range statuses {
+			if !orderstate.IsValid(s) {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "Status tidak dikenal: " + s})
+			}
+		}
+		where = append(where, db.SupplierOrder.Status.In(statuses))
+	}
+
+	if supplierID := c.QueryParam("supplier_id"); supplierID != "" {
+		where = append(where, db.SupplierOrder.SupplierID.Equals(supplierID))
+	}
+
+	now := time.Now()
+	if raw := c.QueryParam("older_than"); raw != "" {
+		age, err := time.ParseDuration(raw)
+		if err != nil || age < 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "older_than harus durasi, contoh: 30m, 2h"})
+		}
+		where = append(where, db.SupplierOrder.CreatedAt.Before(now.Add(-age)))
+	}
+	if raw := c.QueryParam("newer_than"); raw != "" {
+		age, err := time.ParseDuration(raw)
+		if err != nil || age < 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "newer_than harus durasi, contoh: 30m, 2h"})
+		}
+		where = append(where, db.SupplierOrder.CreatedAt.After(now.Add(-age)))
+	}
+
+	limit, offset, err := pageParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	orders, err := h.DB.SupplierOrder.FindMany(where...).With(
+		db.SupplierOrder.Supplier.Fetch(),
+	).OrderBy(
+		db.SupplierOrder.CreatedAt.Order(db.SortOrderAsc),
+	).Take(limit).Skip(offset).Exec(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Supplier orders retrieved successfully",
+		"data":    orders,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// ==========================================
+// 6. DETAIL SUPPLIER ORDER
+// ==========================================
+// Item beserta transaksi per unit, jumlah attempt, last_error, dan riwayat status.
+func (h *AdminHandler) SupplierOrderDetail(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	order, err := h.DB.SupplierOrder.FindUnique(
+		db.SupplierOrder.ID.Equals(c.Param("id")),
+	).With(
+		db.SupplierOrder.Supplier.Fetch(),
+		db.SupplierOrder.InternalOrder.Fetch(),
+		db.SupplierOrder.Items.Fetch().With(
+			db.SupplierOrderItem.SupplierProduct.Fetch(),
+			db.SupplierOrderItem.Transactions.Fetch(),
+		),
+	).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier order tidak ditemukan"})
+	}
+
+	history, err := h.States.History(ctx, orderstate.TypeSupplierOrder, order.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Supplier order retrieved successfully",
+		"data": echo.Map{
+			"order":   order,
+			"history": history,
+		},
+	})
+}
+
+// ==========================================
+// 7. REQUEUE ORDER GAGAL
+// ==========================================
+// failed -> pending dengan jatah attempt di-reset, lalu dimasukkan ke antrian.
+// Unit yang sudah terbeli (completed_qty) tetap dilewati worker.
+func (h *AdminHandler) RequeueSupplierOrder(c echo.Context) error {
+	type Req struct {
+		Reason string `json:"reason"`
+	}
+
+	req := new(Req)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+	if req.Reason == "" {
+		req.Reason = "requeue oleh admin"
+	}
+
+	ctx := c.Request().Context()
+
+	order, err := h.DB.SupplierOrder.FindUnique(db.SupplierOrder.ID.Equals(c.Param("id"))).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier order tidak ditemukan"})
+	}
+	if order.Status != orderstate.Failed {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Hanya order 'failed' yang bisa di-requeue (status: " + order.Status + ")"})
+	}
+
+	ok, err := h.States.SupplierOrder(ctx, orderstate.SupplierChange{
+		OrderID: order.ID,
+		From:    orderstate.Failed,
+		To:      orderstate.Pending,
+		Reason:  req.Reason,
+		Actor:   adminActor(c),
+		Set: []db.SupplierOrderSetParam{
+			db.SupplierOrder.Attempt.Set(0),
+			db.SupplierOrder.NextAttemptAt.Set(time.Now()),
+		},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Status order sudah berubah, silakan muat ulang"})
+	}
+
+	// Internal order ikut kembali ke pending (jika memang ikut gagal)
+	if _, err := h.States.InternalOrder(ctx, orderstate.InternalChange{
+		OrderID: order.InternalOrderID,
+		From:    orderstate.Failed,
+		To:      orderstate.Pending,
+		Reason:  req.Reason,
+		Actor:   adminActor(c),
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	h.Pool.NotifyOrderStatus(ctx, order.InternalOrderID, orderstate.Pending, "", false)
+
+	// Gagal enqueue tidak fatal: rescan worker akan memasukkan order pending ke antrian
+	queued := true
+	if _, err := h.Pool.Queue.EnqueueIfAbsent(ctx, order.SupplierID, order.ID); err != nil {
+		log.Printf("⚠️ Gagal enqueue order %s (requeue admin): %v", order.ID, err)
+		queued = false
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Order dimasukkan ulang ke antrian",
+		"data": echo.Map{
+			"supplier_order_id": order.ID,
+			"internal_order_id": order.InternalOrderID,
+			"queued":            queued,
+		},
+	})
+}
+
+// ==========================================
+// 8. BATALKAN ORDER PENDING
+// ==========================================
+// Hanya order yang belum diklaim worker (status masih pending). Klaim worker
+// juga conditional update dari pending, sehingga hanya salah satu yang menang.
+func (h *AdminHandler) CancelSupplierOrder(c echo.Context) error {
+	type Req struct {
+		Reason string `json:"reason"`
+	}
+
+	req := new(Req)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "reason required"})
+	}
+
+	ctx := c.Request().Context()
+
+	order, err := h.DB.SupplierOrder.FindUnique(db.SupplierOrder.ID.Equals(c.Param("id"))).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier order tidak ditemukan"})
+	}
+
+	ok, err := h.States.SupplierOrder(ctx, orderstate.SupplierChange{
+		OrderID: order.ID,
+		From:    orderstate.Pending,
+		To:      orderstate.Cancelled,
+		Reason:  req.Reason,
+		Actor:   adminActor(c),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Order sudah diklaim worker / bukan pending lagi"})
+	}
+
+	// Job yang tersisa di Redis dibuang; yang sudah terambil worker akan gagal klaim
+	if err := h.Pool.Queue.Remove(ctx, order.SupplierID, order.ID); err != nil {
+		log.Printf("⚠️ Gagal membuang job %s dari antrian: %v", order.ID, err)
+	}
+
+	if _, err := h.States.InternalOrder(ctx, orderstate.InternalChange{
+		OrderID: order.InternalOrderID,
+		From:    orderstate.Pending,
+		To:      orderstate.Cancelled,
+		Reason:  req.Reason,
+		Actor:   adminActor(c),
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	h.Pool.NotifyOrderStatus(ctx, order.InternalOrderID, orderstate.Cancelled, "", false)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Order dibatalkan",
+		"data": echo.Map{
+			"supplier_order_id": order.ID,
+			"internal_order_id": order.InternalOrderID,
+		},
+	})
+}
+
+// ==========================================
+// 9. PAUSE / RESUME SUPPLIER
+// ==========================================
+// Worker berhenti mengklaim order supplier yang di-pause; order yang sedang
+// diproses tetap diselesaikan dan order baru tetap masuk antrian.
+func (h *AdminHandler) PauseSupplier(c echo.Context) error {
+	return h.setSupplierPaused(c, true)
+}
+
+func (h *AdminHandler) ResumeSupplier(c echo.Context) error {
+	return h.setSupplierPaused(c, false)
+}
+
+func (h *AdminHandler) setSupplierPaused(c echo.Context, paused bool) error {
+	type Req struct {
+		Reason string `json:"reason"`
+	}
+
+	req := new(Req)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	ctx := c.Request().Context()
+
+	supplier, err := h.DB.Supplier.FindUnique(
+		db.Supplier.ID.Equals(c.Param("id")),
+	).Update(
+		db.Supplier.Paused.Set(paused),
+		db.Supplier.PausedBy.Set(adminActor(c)),
+		db.Supplier.PausedReason.Set(req.Reason),
+		db.Supplier.PausedAt.Set(time.Now()),
+	).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier tidak ditemukan"})
+	}
+
+	driver.NotifySupplierChanged(ctx, h.Pool.Redis, supplier.ID)
+
+	message := "Supplier di-resume"
+	if paused {
+		message = "Supplier di-pause"
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": message,
+		"data": echo.Map{
+			"supplier_id": supplier.ID,
+			"paused":      supplier.Paused,
+			"paused_by":   adminActor(c),
+			"reason":      req.Reason,
+		},
+	})
+}
+
+// ==========================================
+// 10. WEBHOOK SELLER (Outbox)
+// ==========================================
+// Filter opsional: user_id, order_id (internal order), status (pending, delivered, failed)
+func (h *AdminHandler) ListWebhooks(c echo.Context) error {
+	limit, offset, err := pageParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	deliveries, err := h.Pool.Webhooks.List(c.Request().Context(), services.WebhookFilter{
+		UserID:  c.QueryParam("user_id"),
+		OrderID: c.QueryParam("order_id"),
+		Status:  c.QueryParam("status"),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Webhook deliveries retrieved successfully",
+		"data":    deliveries,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+func (h *AdminHandler) WebhookDetail(c echo.Context) error {
+	delivery, err := h.Pool.Webhooks.Get(c.Request().Context(), c.Param("id"), "")
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Webhook delivery retrieved successfully",
+		"data":    delivery,
+	})
+}
+
+// RedeliverWebhook mengirim ulang webhook saat itu juga, termasuk yang sudah failed / delivered
+func (h *AdminHandler) RedeliverWebhook(c echo.Context) error {
+	return redeliverWebhook(c, h.Pool.Webhooks, "", adminActor(c))
+}
+
+// redeliverWebhook dipakai bersama handler admin & seller
+func redeliverWebhook(c echo.Context, outbox *services.WebhookOutbox, userID, actor string) error {
+	delivery, attempt, err := outbox.Redeliver(c.Request().Context(), c.Param("id"), userID, actor)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	}
+	if errors.Is(err, services.ErrWebhookBusy) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Webhook dikirim ulang",
+		"data": echo.Map{
+			"delivery": delivery,
+			"attempt":  attempt,
+		},
+	})
+}
@@ -26,4 +26,40 @@ func (h *PaymentTypeHandler) GetAll(c echo.Context) error {
 		"message": "Berhasil mengambil data payment types",
 		"data":    paymentTypes,
 	})
-}
\ No newline at end of file
+}
+
+// Update mengubah nama / masa berlaku URL pembayaran (payment_ttl_minutes)
+func (h *PaymentTypeHandler) Update(c echo.Context) error {
+	id := c.Param("id")
+
+	type Req struct {
+		Name              string `json:"name"`
+		PaymentTTLMinutes int    `json:"payment_ttl_minutes"` // Masa berlaku URL pembayaran (menit)
+	}
+
+	req := new(Req)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(400, echo.Map{"error": "Invalid request"})
+	}
+
+	var updates []db.PaymentTypeSetParam
+	if req.Name != "" {
+		updates = append(updates, db.PaymentType.Name.Set(req.Name))
+	}
+	if req.PaymentTTLMinutes < 0 {
+		return c.JSON(400, echo.Map{"error": "payment_ttl_minutes tidak boleh negatif"})
+	}
+	if req.PaymentTTLMinutes > 0 {
+		updates = append(updates, db.PaymentType.PaymentTTLMinutes.Set(req.PaymentTTLMinutes))
+	}
+
+	paymentType, err := h.DB.PaymentType.FindUnique(
+		db.PaymentType.ID.Equals(id),
+	).Update(updates...).Exec(c.Request().Context())
+
+	if err != nil {
+		return c.JSON(500, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(200, echo.Map{"message": "Payment type updated", "data": paymentType})
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"gerbangapi/app/services"
+	"gerbangapi/app/services/orderstate"
 	"gerbangapi/app/utils"
 	"gerbangapi/prisma/db"
 
@@ -15,17 +17,23 @@ import (
 )
 
 type SellerHandler struct {
-	DB           *db.PrismaClient
-	OrderService *services.OrderService
-	Redis        *redis.Client
+	DB                 *db.PrismaClient
+	OrderService       *services.OrderService
+	DestinationService *services.DestinationService
+	States             *orderstate.Machine
+	Redis              *redis.Client
+	Webhooks           *services.WebhookOutbox
 }
 
 // NewSellerHandler menginisialisasi handler dengan DB, Service, dan Redis
-func NewSellerHandler(dbClient *db.PrismaClient, orderService *services.OrderService, redisClient *redis.Client) *SellerHandler {
+func NewSellerHandler(dbClient *db.PrismaClient, orderService *services.OrderService, destinationService *services.DestinationService, redisClient *redis.Client, webhooks *services.WebhookOutbox) *SellerHandler {
 	return &SellerHandler{
-		DB:           dbClient,
-		OrderService: orderService,
-		Redis:        redisClient,
+		DB:                 dbClient,
+		OrderService:       orderService,
+		DestinationService: destinationService,
+		States:             orderstate.New(dbClient),
+		Redis:              redisClient,
+		Webhooks:           webhooks,
 	}
 }
 
@@ -173,6 +181,94 @@ func (h *SellerHandler) UpdateProfile(c echo.Context) error {
 	})
 }
 
+// ==========================================
+// 2b. ROTASI SECRET (Signature Webhook)
+// ==========================================
+// Secret baru langsung dipakai menandatangani webhook. Selama masa transisi,
+// header X-Webhook-Signature juga memuat signature dari secret lama sehingga
+// seller bisa mengganti secret di servernya tanpa ada webhook yang ditolak.
+func (h *SellerHandler) RotateSecret(c echo.Context) error {
+	apiKey := c.Request().Header.Get("X-API-KEY")
+	if apiKey == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Missing X-API-KEY header"})
+	}
+
+	key, err := services.RotateApiKeySecret(c.Request().Context(), h.DB, apiKey)
+	if errors.Is(err, services.ErrSecretRotated) {
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to rotate secret: " + err.Error()})
+	}
+
+	rotatedAt, _ := key.SecretRotatedAt()
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Secret rotated successfully",
+		"data": echo.Map{
+			"api_key":                  key.APIKey,
+			"secret":                   key.Secret,
+			"rotated_at":               rotatedAt,
+			"previous_secret_valid_to": rotatedAt.Add(services.WebhookSecretGrace),
+		},
+	})
+}
+
+// ==========================================
+// 2c. HUBUNGKAN / PUTUSKAN TELEGRAM
+// ==========================================
+// Token link sekali pakai untuk command bot "/start <token>". Akun yang sudah
+// terhubung harus diputus dulu agar binding lama tidak bisa diambil alih.
+func (h *SellerHandler) TelegramLink(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := h.DB.User.FindUnique(db.User.ID.Equals(userID)).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+	if chatID, linked := user.TelegramChatID(); linked && chatID != "" {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Telegram already linked, unlink it first"})
+	}
+
+	token, err := services.IssueTelegramLinkToken(ctx, h.Redis, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Telegram link token created",
+		"data": echo.Map{
+			"token":      token,
+			"command":    "/start " + token,
+			"link":       services.TelegramDeepLink(token),
+			"expires_in": int(services.TelegramLinkTTL.Seconds()),
+		},
+	})
+}
+
+func (h *SellerHandler) TelegramUnlink(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	// Set NULL lewat raw query (sama seperti command /unlink)
+	_, err := h.DB.Prisma.ExecuteRaw(
+		"UPDATE `user` SET telegram_chat_id = NULL WHERE id = ?",
+		userID,
+	).Exec(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to unlink Telegram: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Telegram unlinked successfully"})
+}
+
 // ==========================================
 // 3. GET SELLER PRODUCTS
 // ==========================================
@@ -199,8 +295,10 @@ func (h *SellerHandler) SellerOrder(c echo.Context) error {
 		Destination   string `json:"destination"`
 		RefID         string `json:"ref_id"`
 		SupplierID    string `json:"supplier_id"`
-		WebhookURL    string `json:"webhook_url"` // Opsional
+		WebhookURL    string `json:"webhook_url"` // Opsional: callback khusus order ini (menimpa webhook_url profil)
 		PaymentTypeID string `json:"payment_type_id"` // [BARU] Tambahan field metode pembayaran
+
+		CheckDestination bool `json:"check_destination"` // Opsional: validasi ID tujuan ke supplier dulu
 	}
 
 	req := new(Req)
@@ -213,6 +311,13 @@ func (h *SellerHandler) SellerOrder(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "product_id, destination, supplier_id, dan payment_type_id required"})
 	}
 
+	req.WebhookURL = strings.TrimSpace(req.WebhookURL)
+	if req.WebhookURL != "" {
+		if err := utils.ValidateWebhookURL(req.WebhookURL); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+	}
+
 	ctx := c.Request().Context()
 
 	// A. VALIDASI PRODUCT
@@ -231,6 +336,21 @@ func (h *SellerHandler) SellerOrder(c echo.Context) error {
 	}
 	realProductUUID := product.ID
 
+	// A2. VALIDASI ID TUJUAN (Opsional, sinkron ke supplier)
+	if req.CheckDestination {
+		result, err := h.DestinationService.Check(ctx, realProductUUID, req.SupplierID, req.Destination)
+		if errors.Is(err, services.ErrDestinationNotFound) {
+			return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+				"error":       "Destination tidak ditemukan di supplier",
+				"destination": req.Destination,
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusServiceUnavailable, echo.Map{"error": "Gagal memvalidasi destination: " + err.Error()})
+		}
+		log.Printf("✅ Destination %s valid (nickname: %s)", req.Destination, result.Nickname)
+	}
+
 	// B. AMBIL USER ID (Dari Context Middleware)
 	// Pastikan SellerSecurityMiddleware sudah men-set "user_id"
 	userID, ok := c.Get("user_id").(string)
@@ -254,14 +374,15 @@ func (h *SellerHandler) SellerOrder(c echo.Context) error {
 	internalOrderID := uuid.New().String()
 	_, err = h.DB.Prisma.ExecuteRaw(
 		`INSERT INTO internal_order 
-         (id, product_id, user_id, payment_type_id, buyer_uid, quantity, status, created_at, updated_at) 
-         VALUES (?, ?, ?, ?, ?, ?, 'pending', NOW(), NOW())`,
-		internalOrderID, realProductUUID, userID, req.PaymentTypeID, req.Destination, 1,
+         (id, product_id, user_id, payment_type_id, buyer_uid, quantity, webhook_url, status, created_at, updated_at) 
+         VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), 'pending', NOW(), NOW())`,
+		internalOrderID, realProductUUID, userID, req.PaymentTypeID, req.Destination, 1, req.WebhookURL,
 	).Exec(ctx)
 
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Database error: " + err.Error()})
 	}
+	h.States.Created(ctx, orderstate.TypeInternalOrder, internalOrderID, orderstate.Pending, "seller:"+userID)
 
 	// D. MIXING PROCESS (Memecah menjadi Supplier Order)
 	// Fungsi ini akan membuat row di tabel supplier_order dengan status 'pending'
@@ -269,7 +390,13 @@ func (h *SellerHandler) SellerOrder(c echo.Context) error {
 
 	if mixErr != nil {
 		// Update failed jika mixing gagal
-		h.DB.Prisma.ExecuteRaw("UPDATE internal_order SET status='failed' WHERE id=?", internalOrderID).Exec(ctx)
+		h.States.InternalOrder(ctx, orderstate.InternalChange{
+			OrderID: internalOrderID,
+			From:    orderstate.Pending,
+			To:      orderstate.Failed,
+			Reason:  "mixing gagal: " + mixErr.Error(),
+			Actor:   "seller:" + userID,
+		})
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Mixing failed: " + mixErr.Error()})
 	}
 
@@ -286,6 +413,38 @@ func (h *SellerHandler) SellerOrder(c echo.Context) error {
 	})
 }
 
+// ==========================================
+// 4b. CHECK DESTINATION (Validasi ID Tujuan)
+// ==========================================
+func (h *SellerHandler) CheckDestination(c echo.Context) error {
+	productID := c.QueryParam("product_id")
+	destination := strings.TrimSpace(c.QueryParam("destination"))
+	supplierID := c.QueryParam("supplier_id") // Opsional, default supplier produk
+
+	if productID == "" || destination == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "product_id dan destination required"})
+	}
+
+	result, err := h.DestinationService.Check(c.Request().Context(), productID, supplierID, destination)
+	if errors.Is(err, services.ErrDestinationNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"error": "User tidak ditemukan",
+			"data":  result,
+		})
+	}
+	if errors.Is(err, services.ErrSupplierBusy) {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, echo.Map{"error": "Gagal cek destination: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Destination valid",
+		"data":    result,
+	})
+}
+
 // ==========================================
 // 5. GET HISTORY ORDER
 // ==========================================
@@ -307,48 +466,162 @@ func (h *SellerHandler) HistoryOrder(c echo.Context) error {
 	var response []map[string]interface{}
 
 	for _, o := range orders {
-		productName := "Unknown Product"
-		productPrice := 0
-
-		// Ambil Product
-		p := o.Product()
-		if p != nil {
-			productName = p.Name
-			productPrice = p.Price
-		}
+		response = append(response, sellerOrderView(o))
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Order History retrieved successfully",
+		"data":    response,
+	})
+}
+
+func (h *SellerHandler) OrderStatus(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	// Order hanya bisa dilihat oleh pemiliknya
+	order, err := h.DB.InternalOrder.FindFirst(
+		db.InternalOrder.ID.Equals(c.Param("id")),
+		db.InternalOrder.UserID.Equals(userID),
+	).With(
+		db.InternalOrder.Product.Fetch(),
+		db.InternalOrder.SupplierOrders.Fetch().With(
+			services.SupplierOrderWithTransactions(),
+		),
+	).Exec(c.Request().Context())
 
-		sn := "-"
-		
-		// Ambil SN dari SupplierOrder pertama yang punya TrxID
-		sos := o.SupplierOrders()
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Order tidak ditemukan"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Failed to fetch order: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Order status retrieved successfully",
+		"data":    sellerOrderView(*order),
+	})
+}
+
+// sellerOrderView memetakan internal order ke payload riwayat / status order seller
+func sellerOrderView(o db.InternalOrderModel) map[string]interface{} {
+	productName := "Unknown Product"
+	productPrice := 0
+
+	// Ambil Product
+	p := o.Product()
+	if p != nil {
+		productName = p.Name
+		productPrice = p.Price
+	}
+
+	// Transaksi per unit di supplier (ledger)
+	sos := o.SupplierOrders()
+	transactions := services.TransactionsOf(sos...)
+
+	sn := services.LegacySN(transactions)
+	if sn == "" {
+		sn = "-"
+		// Order lama (sebelum ada ledger): ambil SN dari provider_trx_id
 		for _, so := range sos {
 			if val, ok := so.ProviderTrxID(); ok && val != "" {
 				sn = val
-				break 
+				break
 			}
 		}
+	}
 
-		// [BARU] Ambil Data Payment Type ID jika diperlukan riwayatnya
-		paymentTypeID, _ := o.PaymentTypeID()
-
-		item := map[string]interface{}{
-			"id":              o.ID,
-			"ref_id":          o.ID,
-			"product_name":    productName,
-			"destination":     o.BuyerUID,
-			"payment_type_id": paymentTypeID, // [BARU] Ditambahkan ke payload list riwayat
-			"quantity":        o.Quantity,
-			"status":          o.Status,
-			"sn":              sn,
-			"created_at":      o.CreatedAt,
-			"price":           productPrice,
-		}
+	// [BARU] Ambil Data Payment Type ID jika diperlukan riwayatnya
+	paymentTypeID, _ := o.PaymentTypeID()
+
+	var paymentExpiresAt interface{}
+	if val, ok := o.PaymentExpiresAt(); ok {
+		paymentExpiresAt = val
+	}
+
+	// Callback khusus order (kosong = memakai webhook_url profil)
+	webhookURL, _ := o.WebhookURL()
+
+	return map[string]interface{}{
+		"id":              o.ID,
+		"ref_id":          o.ID,
+		"product_name":    productName,
+		"destination":     o.BuyerUID,
+		"payment_type_id": paymentTypeID, // [BARU] Ditambahkan ke payload list riwayat
+		"quantity":        o.Quantity,
+		"status":          o.Status,
+		"sn":              sn,
+		"transactions":    transactions,
+		"created_at":      o.CreatedAt,
+		"price":           productPrice,
+
+		"payment_expires_at": paymentExpiresAt, // Batas bayar (null jika URL pembayaran belum terbit)
+		"webhook_url":        webhookURL,
+	}
+}
+
+// ==========================================
+// 6. LOG WEBHOOK (Outbox)
+// ==========================================
+// Riwayat pengiriman webhook ke server seller beserta setiap percobaannya.
+// Filter opsional: order_id, status (pending, delivered, failed).
+func (h *SellerHandler) ListWebhooks(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
 
-		response = append(response, item)
+	limit, offset, err := pageParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	deliveries, err := h.Webhooks.List(c.Request().Context(), services.WebhookFilter{
+		UserID:  userID,
+		OrderID: c.QueryParam("order_id"),
+		Status:  c.QueryParam("status"),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
-		"message": "Order History retrieved successfully",
-		"data":    response,
+		"message": "Webhook deliveries retrieved successfully",
+		"data":    deliveries,
+		"limit":   limit,
+		"offset":  offset,
 	})
-}
\ No newline at end of file
+}
+
+func (h *SellerHandler) WebhookDetail(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+
+	delivery, err := h.Webhooks.Get(c.Request().Context(), c.Param("id"), userID)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Webhook delivery retrieved successfully",
+		"data":    delivery,
+	})
+}
+
+// RedeliverWebhook mengirim ulang webhook milik seller saat itu juga
+func (h *SellerHandler) RedeliverWebhook(c echo.Context) error {
+	userID, ok := c.Get("user_id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+	}
+	return redeliverWebhook(c, h.Webhooks, userID, "seller:"+userID)
+}
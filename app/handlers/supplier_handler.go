@@ -1,6 +1,13 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"gerbangapi/app/services/driver"
 	"gerbangapi/app/services/scraper"
 	"gerbangapi/prisma/db"
 
@@ -25,6 +32,9 @@ func (h *SupplierHandler) Create(c echo.Context) error {
 		BaseURL  string `json:"base_url"`
 		Username string `json:"username"`
 		Password string `json:"password"`
+
+		MaxConcurrency int    `json:"max_concurrency"`
+		ExecutionMode  string `json:"execution_mode"`
 	}
 
 	req := new(Req)
@@ -32,6 +42,16 @@ func (h *SupplierHandler) Create(c echo.Context) error {
 		return c.JSON(400, echo.Map{"error": "Invalid request"})
 	}
 
+	if req.MaxConcurrency < 1 {
+		req.MaxConcurrency = 1
+	}
+	if req.ExecutionMode == "" {
+		req.ExecutionMode = driver.ModeBrowser
+	}
+	if !validExecutionMode(req.ExecutionMode) {
+		return c.JSON(400, echo.Map{"error": "execution_mode harus 'browser' atau 'http'"})
+	}
+
 	supplier, err := h.DB.Supplier.CreateOne(
 		db.Supplier.Name.Set(req.Name),
 		db.Supplier.Type.Set(req.Type),
@@ -40,6 +60,8 @@ func (h *SupplierHandler) Create(c echo.Context) error {
 		db.Supplier.Username.SetIfPresent(&req.Username),
 		db.Supplier.Password.SetIfPresent(&req.Password),
 		db.Supplier.Status.Set(true),
+		db.Supplier.MaxConcurrency.Set(req.MaxConcurrency),
+		db.Supplier.ExecutionMode.Set(req.ExecutionMode),
 	).Exec(c.Request().Context())
 
 	if err != nil {
@@ -67,6 +89,9 @@ func (h *SupplierHandler) Update(c echo.Context) error {
 		Username string `json:"username"`
 		Password string `json:"password"`
 		Status   *bool  `json:"status"`
+
+		MaxConcurrency int    `json:"max_concurrency"`
+		ExecutionMode  string `json:"execution_mode"`
 	}
 
 	req := new(Req)
@@ -96,6 +121,15 @@ func (h *SupplierHandler) Update(c echo.Context) error {
 	if req.Status != nil {
 		updates = append(updates, db.Supplier.Status.Set(*req.Status))
 	}
+	if req.MaxConcurrency > 0 {
+		updates = append(updates, db.Supplier.MaxConcurrency.Set(req.MaxConcurrency))
+	}
+	if req.ExecutionMode != "" {
+		if !validExecutionMode(req.ExecutionMode) {
+			return c.JSON(400, echo.Map{"error": "execution_mode harus 'browser' atau 'http'"})
+		}
+		updates = append(updates, db.Supplier.ExecutionMode.Set(req.ExecutionMode))
+	}
 
 	supplier, err := h.DB.Supplier.FindUnique(
 		db.Supplier.ID.Equals(id),
@@ -105,6 +139,8 @@ func (h *SupplierHandler) Update(c echo.Context) error {
 		return c.JSON(500, echo.Map{"error": err.Error()})
 	}
 
+	driver.NotifySupplierChanged(c.Request().Context(), h.Redis, supplier.ID)
+
 	return c.JSON(200, echo.Map{"message": "Updated", "data": supplier})
 }
 
@@ -133,6 +169,7 @@ func (h *SupplierHandler) CheckConnection(c echo.Context) error {
 	password := req.Password
 	supplierName := "New/Unregistered Supplier"
 	supplierID := req.SupplierID
+	config := scraper.DefaultMitraHiggsConfig()
 
 	if supplierID != "" {
 		supplier, err := h.DB.Supplier.FindUnique(
@@ -145,6 +182,13 @@ func (h *SupplierHandler) CheckConnection(c echo.Context) error {
 		
 		supplierName = supplier.Name
 
+		// Tes koneksi memakai selector & URL milik supplier ini
+		rawConfig, _ := supplier.ScraperConfig()
+		baseURL, _ := supplier.BaseURL()
+		if config, err = scraper.ParseMitraHiggsConfig(rawConfig, baseURL); err != nil {
+			return c.JSON(422, echo.Map{"error": err.Error()})
+		}
+
 		if username == "" {
 			valUser, _ := supplier.Username()
 			username = valUser
@@ -164,6 +208,7 @@ func (h *SupplierHandler) CheckConnection(c echo.Context) error {
 		return c.JSON(500, echo.Map{"error": "Gagal memulai service browser", "details": err.Error()})
 	}
 	defer svc.Close()
+	svc.Config = config
 
 	identityData := echo.Map{
 		"supplier_id":   supplierID,
@@ -186,4 +231,166 @@ func (h *SupplierHandler) CheckConnection(c echo.Context) error {
 		"message": "Login berhasil, supplier telah terhubung!",
 		"data":    identityData,
 	})
-}
\ No newline at end of file
+}
+
+func validExecutionMode(mode string) bool {
+	return mode == driver.ModeBrowser || mode == driver.ModeHTTP
+}
+
+// ==========================================
+// KONFIGURASI SCRAPER (Selector, URL, Timeout)
+// ==========================================
+// Konfigurasi disimpan per supplier & diberi versi. Setiap perubahan dicatat di
+// ScraperConfigVersion dan worker di semua replica langsung memuat ulang.
+
+func (h *SupplierHandler) GetScraperConfig(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	supplier, err := h.DB.Supplier.FindUnique(db.Supplier.ID.Equals(c.Param("id"))).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier tidak ditemukan"})
+	}
+
+	rawConfig, _ := supplier.ScraperConfig()
+	baseURL, _ := supplier.BaseURL()
+	config, configErr := scraper.ParseMitraHiggsConfig(rawConfig, baseURL)
+
+	versions, err := h.DB.ScraperConfigVersion.FindMany(
+		db.ScraperConfigVersion.SupplierID.Equals(supplier.ID),
+	).OrderBy(
+		db.ScraperConfigVersion.Version.Order(db.SortOrderDesc),
+	).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	data := echo.Map{
+		"version":  supplier.ScraperConfigVersion,
+		"base_url": baseURL,
+		"config":   config,
+		"defaults": scraper.DefaultMitraHiggsConfig(),
+		"history":  versions,
+	}
+	if configErr != nil {
+		data["config_error"] = configErr.Error()
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Scraper config retrieved successfully", "data": data})
+}
+
+// UpdateScraperConfig menerima JSON konfigurasi (field yang tidak diisi = default,
+// field yang tidak dikenal ditolak).
+// Kirim "version" = versi yang sedang dilihat agar perubahan admin lain tidak tertimpa.
+func (h *SupplierHandler) UpdateScraperConfig(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil || len(body) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request"})
+	}
+
+	supplier, err := h.DB.Supplier.FindUnique(db.Supplier.ID.Equals(c.Param("id"))).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier tidak ditemukan"})
+	}
+
+	var meta struct {
+		Version *int `json:"version"`
+	}
+	json.Unmarshal(body, &meta)
+	if meta.Version != nil && *meta.Version != supplier.ScraperConfigVersion {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"error":   "Konfigurasi sudah diubah admin lain, muat ulang dulu",
+			"version": supplier.ScraperConfigVersion,
+		})
+	}
+
+	baseURL, _ := supplier.BaseURL()
+	config, err := scraper.ParseMitraHiggsConfigStrict(string(body), baseURL)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+
+	return h.saveScraperConfig(c, ctx, supplier, config)
+}
+
+// RollbackScraperConfig memakai kembali konfigurasi versi lama (disimpan sebagai versi baru)
+func (h *SupplierHandler) RollbackScraperConfig(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	type Req struct {
+		Version int `json:"version"`
+	}
+	req := new(Req)
+	if err := c.Bind(req); err != nil || req.Version < 1 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "version wajib diisi"})
+	}
+
+	supplier, err := h.DB.Supplier.FindUnique(db.Supplier.ID.Equals(c.Param("id"))).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Supplier tidak ditemukan"})
+	}
+
+	old, err := h.DB.ScraperConfigVersion.FindFirst(
+		db.ScraperConfigVersion.SupplierID.Equals(supplier.ID),
+		db.ScraperConfigVersion.Version.Equals(req.Version),
+	).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Versi konfigurasi tidak ditemukan"})
+	}
+
+	baseURL, _ := supplier.BaseURL()
+	config, err := scraper.ParseMitraHiggsConfig(old.Config, baseURL)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+
+	return h.saveScraperConfig(c, ctx, supplier, config)
+}
+
+// saveScraperConfig menyimpan konfigurasi sebagai versi baru (conditional update
+// pada versi lama), mencatat riwayatnya, lalu memberi tahu worker
+func (h *SupplierHandler) saveScraperConfig(c echo.Context, ctx context.Context, supplier *db.SupplierModel, config *scraper.MitraHiggsConfig) error {
+	version := supplier.ScraperConfigVersion + 1
+	config.Version = version
+
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	result, err := h.DB.Supplier.FindMany(
+		db.Supplier.ID.Equals(supplier.ID),
+		db.Supplier.ScraperConfigVersion.Equals(supplier.ScraperConfigVersion),
+	).Update(
+		db.Supplier.ScraperConfig.Set(string(raw)),
+		db.Supplier.ScraperConfigVersion.Set(version),
+	).Exec(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if result.Count == 0 {
+		return c.JSON(http.StatusConflict, echo.Map{"error": "Konfigurasi sudah diubah admin lain, muat ulang dulu"})
+	}
+
+	_, err = h.DB.ScraperConfigVersion.CreateOne(
+		db.ScraperConfigVersion.Version.Set(version),
+		db.ScraperConfigVersion.Config.Set(string(raw)),
+		db.ScraperConfigVersion.Actor.Set(adminActor(c)),
+		db.ScraperConfigVersion.Supplier.Link(db.Supplier.ID.Equals(supplier.ID)),
+	).Exec(ctx)
+	if err != nil {
+		// Konfigurasi aktif sudah tersimpan; hanya riwayatnya yang hilang
+		log.Printf("⚠️ Gagal mencatat riwayat konfigurasi scraper %s v%d: %v", supplier.ID, version, err)
+	}
+
+	driver.NotifySupplierChanged(ctx, h.Redis, supplier.ID)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"message": "Scraper config updated",
+		"data": echo.Map{
+			"version": version,
+			"config":  config,
+		},
+	})
+}
@@ -1,29 +1,44 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/orderstate"
 	"gerbangapi/prisma/db"
 
 	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+// Batas jumlah order yang ditampilkan command /history
+const (
+	telegramHistoryDefault = 5
+	telegramHistoryMax     = 20
 )
 
 type TelegramHandler struct {
-	DB *db.PrismaClient
+	DB       *db.PrismaClient
+	Redis    *redis.Client // Token link /start sekali pakai
+	Telegram *services.TelegramClient
 }
 
-func NewTelegramHandler(dbClient *db.PrismaClient) *TelegramHandler {
-	return &TelegramHandler{DB: dbClient}
+func NewTelegramHandler(dbClient *db.PrismaClient, redisClient *redis.Client, telegram *services.TelegramClient) *TelegramHandler {
+	return &TelegramHandler{DB: dbClient, Redis: redisClient, Telegram: telegram}
 }
 
 // Struct untuk memparsing JSON dari Telegram
 type TelegramUpdate struct {
 	UpdateID int `json:"update_id"`
 	Message  struct {
-		MessageID int    `json:"message_id"`
+		MessageID int `json:"message_id"`
 		From      struct {
 			ID        int64  `json:"id"`
 			FirstName string `json:"first_name"`
@@ -36,63 +51,281 @@ type TelegramUpdate struct {
 	} `json:"message"`
 }
 
+// telegramHelp adalah daftar command yang dikenali bot
+const telegramHelp = `<b>🤖 PERINTAH BOT</b>
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+/status <code>ref_id</code> - Cek status transaksi
+/history [jumlah] - Transaksi terakhir (default 5, maks 20)
+/unlink - Putuskan akun dari chat ini
+/help - Tampilkan bantuan ini
+
+Untuk menghubungkan akun, buka link Telegram dari dashboard seller.`
+
+// Header yang dikirim Telegram berisi secret_token saat setWebhook
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
 // Method untuk menerima Webhook
 func (h *TelegramHandler) HandleWebhook(c echo.Context) error {
 	var update TelegramUpdate
-	
+
+	// 0. Pastikan request benar dari Telegram: header harus sama dengan
+	// TELEGRAM_WEBHOOK_SECRET (secret_token saat setWebhook). Jika secret belum
+	// diset, semua request ditolak agar /start tidak bisa dipalsukan.
+	secret := c.Request().Header.Get(telegramSecretHeader)
+	if h.Telegram.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.Telegram.WebhookSecret)) != 1 {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
 	// 1. Bind JSON dari Telegram
 	if err := c.Bind(&update); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid payload"})
 	}
 
-	messageText := update.Message.Text
+	messageText := strings.TrimSpace(update.Message.Text)
 	chatID := update.Message.Chat.ID
-	
+
+	// Update tanpa pesan teks (edit, callback, dll) diabaikan
+	if messageText == "" || chatID == 0 {
+		return c.JSON(http.StatusOK, "OK")
+	}
+
 	// Log untuk debug
 	log.Printf("📩 Telegram msg received: %s | ChatID: %d", messageText, chatID)
 
-	// 2. Cek apakah ini command /start dengan Payload (Deep Linking)
-	// Format pesan akan terlihat seperti: "/start c545e249-3c5e-4172-..."
-	if strings.HasPrefix(messageText, "/start ") {
-		
-		// Ambil UUID (pisahkan "/start " dengan ID)
-		parts := strings.Split(messageText, " ")
-		if len(parts) < 2 {
-			return c.JSON(http.StatusOK, "No token provided")
-		}
-		
-		userUUID := parts[1] // Ini UUID User dari Database
-
-		// 3. Update Database: Simpan Chat ID ke User tersebut
-		// Convert int64 ChatID ke string
-		chatIDStr := fmt.Sprintf("%d", chatID)
-
-		_, err := h.DB.User.FindUnique(
-			db.User.ID.Equals(userUUID),
-		).Update(
-			db.User.TelegramChatID.Set(chatIDStr),
-		).Exec(c.Request().Context())
-
-		if err != nil {
-			log.Printf("❌ Gagal update user binding: %v", err)
-			h.sendReply(chatID, "❌ Gagal menghubungkan akun. Pastikan ID valid atau hubungi admin.")
-			return c.JSON(http.StatusOK, "Failed")
-		}
+	// 2. Pisahkan command & argumen. Di grup, command bisa berbentuk "/status@NamaBot"
+	fields := strings.Fields(messageText)
+	command := strings.ToLower(fields[0])
+	if at := strings.Index(command, "@"); at > 0 {
+		command = command[:at]
+	}
+	args := fields[1:]
 
-		// 4. Sukses! Balas ke User
-		successMsg := fmt.Sprintf("✅ <b>BERHASIL!</b>\n\nHalo %s, akun Anda telah terhubung.\nNotifikasi transaksi akan dikirim ke sini.", update.Message.From.FirstName)
-		h.sendReply(chatID, successMsg)
+	switch command {
+	case "/start":
+		h.handleStart(c, chatID, update.Message.From.FirstName, args)
+	case "/help":
+		h.sendReply(chatID, telegramHelp)
+	case "/status":
+		h.handleStatus(c, chatID, args)
+	case "/history":
+		h.handleHistory(c, chatID, args)
+	case "/unlink":
+		h.handleUnlink(c, chatID)
+	default:
+		h.sendReply(chatID, "❓ Perintah tidak dikenal.\n\n"+telegramHelp)
 	}
 
 	// Selalu return 200 OK agar Telegram tidak mengirim ulang pesan
 	return c.JSON(http.StatusOK, "OK")
 }
 
-// Helper simpel untuk membalas pesan
+// =================================================================
+// 1. /start <token> - Hubungkan akun (Deep Linking)
+// =================================================================
+// Token sekali pakai diterbitkan lewat API seller (POST /seller/telegram/link)
+// dan hangus setelah services.TelegramLinkTTL. Format pesan: "/start 3f9a1c..."
+func (h *TelegramHandler) handleStart(c echo.Context, chatID int64, firstName string, args []string) {
+	if len(args) == 0 {
+		h.sendReply(chatID, "👋 Halo! Untuk menghubungkan akun, buka link Telegram dari dashboard seller.\n\n"+telegramHelp)
+		return
+	}
+
+	ctx := c.Request().Context()
+
+	userID, err := services.RedeemTelegramLinkToken(ctx, h.Redis, args[0])
+	if err != nil {
+		if !errors.Is(err, services.ErrTelegramLinkInvalid) {
+			log.Printf("❌ Gagal membaca token link Telegram: %v", err)
+		}
+		h.sendReply(chatID, "❌ Link tidak valid atau sudah kedaluwarsa. Buat link baru dari dashboard seller.")
+		return
+	}
+
+	// Hanya akun yang belum terhubung yang bisa di-bind; binding lama tidak
+	// ditimpa (putuskan dulu lewat /unlink atau dashboard seller)
+	result, err := h.DB.User.FindMany(
+		db.User.ID.Equals(userID),
+		db.User.TelegramChatID.IsNull(),
+	).Update(
+		db.User.TelegramChatID.Set(strconv.FormatInt(chatID, 10)),
+	).Exec(ctx)
+
+	if err != nil {
+		log.Printf("❌ Gagal update user binding: %v", err)
+		h.sendReply(chatID, "❌ Gagal menghubungkan akun, silakan coba lagi nanti.")
+		return
+	}
+	if result.Count == 0 {
+		h.sendReply(chatID, "⚠️ Akun ini sudah terhubung ke chat Telegram lain. Putuskan dulu (/unlink di chat lama atau lewat dashboard seller), lalu buat link baru.")
+		return
+	}
+
+	successMsg := fmt.Sprintf("✅ <b>BERHASIL!</b>\n\nHalo %s, akun Anda telah terhubung.\nNotifikasi transaksi akan dikirim ke sini.\n\nKetik /help untuk melihat perintah lain.", html.EscapeString(firstName))
+	h.sendReply(chatID, successMsg)
+}
+
+// =================================================================
+// 2. /status <ref_id> - Cek status satu transaksi milik akun
+// =================================================================
+func (h *TelegramHandler) handleStatus(c echo.Context, chatID int64, args []string) {
+	user, ok := h.linkedUser(c, chatID)
+	if !ok {
+		return
+	}
+
+	if len(args) == 0 {
+		h.sendReply(chatID, "⚠️ Format: /status <code>ref_id</code>")
+		return
+	}
+
+	// Hanya order milik user yang terhubung ke chat ini
+	order, err := h.DB.InternalOrder.FindFirst(
+		db.InternalOrder.ID.Equals(args[0]),
+		db.InternalOrder.UserID.Equals(user.ID),
+	).With(
+		db.InternalOrder.Product.Fetch(),
+		db.InternalOrder.SupplierOrders.Fetch().With(
+			services.SupplierOrderWithTransactions(),
+		),
+	).Exec(c.Request().Context())
+
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			h.sendReply(chatID, "❌ Transaksi tidak ditemukan.")
+			return
+		}
+		log.Printf("❌ Gagal membaca order %s untuk Telegram: %v", args[0], err)
+		h.sendReply(chatID, "❌ Terjadi kesalahan, silakan coba lagi nanti.")
+		return
+	}
+
+	// Tautan pembayaran hanya relevan saat menunggu pembayaran
+	var urlLinks string
+	if order.Status == orderstate.AwaitingPayment {
+		for idx, tx := range services.TransactionsOf(order.SupplierOrders()...) {
+			if tx.PaymentURL != "" {
+				urlLinks += fmt.Sprintf("\n🔗 <a href=\"%s\">Bayar Bagian %d</a>", html.EscapeString(tx.PaymentURL), idx+1)
+			}
+		}
+		if expiresAt, ok := order.PaymentExpiresAt(); ok {
+			urlLinks += fmt.Sprintf("\n<b>Batas Bayar:</b> %s", expiresAt.Format("02 Jan 2006 15:04"))
+		}
+	}
+
+	msg := fmt.Sprintf(`<b>📄 STATUS TRANSAKSI</b>
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<b>Produk:</b> %s
+📍 <b>Tujuan:</b> <code>%s</code>
+<b>Tanggal:</b> %s
+<b>Status:</b> <pre>%s</pre>%s
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<i>Ref ID: %s</i>`,
+		html.EscapeString(order.Product().Name),
+		html.EscapeString(order.BuyerUID),
+		order.CreatedAt.Format("02 Jan 2006 15:04"),
+		order.Status,
+		urlLinks,
+		order.ID,
+	)
+	h.sendReply(chatID, msg)
+}
+
+// =================================================================
+// 3. /history [jumlah] - Transaksi terakhir milik akun
+// =================================================================
+func (h *TelegramHandler) handleHistory(c echo.Context, chatID int64, args []string) {
+	user, ok := h.linkedUser(c, chatID)
+	if !ok {
+		return
+	}
+
+	limit := telegramHistoryDefault
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			h.sendReply(chatID, "⚠️ Format: /history [jumlah]")
+			return
+		}
+		limit = min(n, telegramHistoryMax)
+	}
+
+	orders, err := h.DB.InternalOrder.FindMany(
+		db.InternalOrder.UserID.Equals(user.ID),
+	).With(
+		db.InternalOrder.Product.Fetch(),
+	).OrderBy(
+		db.InternalOrder.CreatedAt.Order(db.SortOrderDesc),
+	).Take(limit).Exec(c.Request().Context())
+
+	if err != nil {
+		log.Printf("❌ Gagal membaca history Telegram user %s: %v", user.ID, err)
+		h.sendReply(chatID, "❌ Terjadi kesalahan, silakan coba lagi nanti.")
+		return
+	}
+
+	if len(orders) == 0 {
+		h.sendReply(chatID, "📭 Belum ada transaksi.")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>🧾 %d TRANSAKSI TERAKHIR</b>\n▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬", len(orders))
+	for _, o := range orders {
+		fmt.Fprintf(&sb, "\n\n🔹 %s\n📍 <code>%s</code> • <b>%s</b>\n%s\n<code>%s</code>",
+			html.EscapeString(o.Product().Name),
+			html.EscapeString(o.BuyerUID),
+			o.Status,
+			o.CreatedAt.Format("02 Jan 2006 15:04"),
+			o.ID,
+		)
+	}
+	sb.WriteString("\n\nKetik /status <code>ref_id</code> untuk detail.")
+	h.sendReply(chatID, sb.String())
+}
+
+// =================================================================
+// 4. /unlink - Putuskan akun dari chat ini
+// =================================================================
+func (h *TelegramHandler) handleUnlink(c echo.Context, chatID int64) {
+	if _, ok := h.linkedUser(c, chatID); !ok {
+		return
+	}
+
+	// Set NULL lewat raw query: semua akun yang terhubung ke chat ini diputus
+	_, err := h.DB.Prisma.ExecuteRaw(
+		"UPDATE `user` SET telegram_chat_id = NULL WHERE telegram_chat_id = ?",
+		strconv.FormatInt(chatID, 10),
+	).Exec(c.Request().Context())
+
+	if err != nil {
+		log.Printf("❌ Gagal unlink Telegram chat %d: %v", chatID, err)
+		h.sendReply(chatID, "❌ Gagal memutus akun, silakan coba lagi nanti.")
+		return
+	}
+
+	h.sendReply(chatID, "✅ Akun telah diputus. Notifikasi transaksi tidak akan dikirim ke chat ini lagi.")
+}
+
+// linkedUser mencari user yang terhubung ke chat ini. Jika belum terhubung,
+// balasan petunjuk langsung dikirim dan ok = false.
+func (h *TelegramHandler) linkedUser(c echo.Context, chatID int64) (*db.UserModel, bool) {
+	user, err := h.DB.User.FindFirst(
+		db.User.TelegramChatID.Equals(strconv.FormatInt(chatID, 10)),
+	).Exec(c.Request().Context())
+
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			log.Printf("❌ Gagal membaca user Telegram chat %d: %v", chatID, err)
+			h.sendReply(chatID, "❌ Terjadi kesalahan, silakan coba lagi nanti.")
+			return nil, false
+		}
+		h.sendReply(chatID, "🔒 Chat ini belum terhubung ke akun mana pun. Buka link Telegram dari dashboard seller untuk menghubungkan.")
+		return nil, false
+	}
+	return user, true
+}
+
+// sendReply membalas pesan lewat client Telegram yang sama dengan worker
 func (h *TelegramHandler) sendReply(chatID int64, text string) {
-	// Panggil API sendMessage (Sederhana, pakai http.Post biasa atau copas logic dari worker)
-	// Implementasi detailnya mirip function sendTelegramNotification di worker
-    // ... (Code request ke https://api.telegram.org/bot<TOKEN>/sendMessage) ...
-    // Agar singkat, saya skip implementasi detail HTTP client disini, 
-    // tapi logic-nya sama persis dengan yang ada di worker.
-}
\ No newline at end of file
+	h.Telegram.SendAsync(strconv.FormatInt(chatID, 10), text)
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gerbangapi/prisma/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminMiddleware: Pastikan user dari JWT memiliki role "Admin".
+// Wajib dipasang SETELAH JWTMiddleware (butuh "user_id" di context).
+func AdminMiddleware(client *db.PrismaClient) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := c.Get("user_id").(string)
+			if !ok || userID == "" {
+				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Unauthorized"})
+			}
+
+			user, err := client.User.FindUnique(
+				db.User.ID.Equals(userID),
+			).With(
+				db.User.Role.Fetch(),
+			).Exec(c.Request().Context())
+
+			if err != nil || user == nil {
+				return c.JSON(http.StatusUnauthorized, echo.Map{"error": "User not found"})
+			}
+
+			role, ok := user.Role()
+			if !ok || role.Name != "Admin" {
+				return c.JSON(http.StatusForbidden, echo.Map{"error": "Admin access required"})
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -20,6 +20,7 @@ func Init(
 	recipeHandler *handlers.RecipeHandler,
 	telegramHandler *handlers.TelegramHandler,
 	paymentTypeHandler *handlers.PaymentTypeHandler,
+	adminHandler *handlers.AdminHandler,
 ) {
 	// Grouping v1
 	v1 := e.Group("/api/v1")
@@ -83,17 +84,59 @@ func Init(
 
 	// --- 7. [BARU] Payment Types ---
 	protected.GET("/payment-types", paymentTypeHandler.GetAll)
+	protected.PUT("/payment-types/:id", paymentTypeHandler.Update)
 
 	// ==========================================
-	// C. SELLER ROUTES (Butuh API KEY)
+	// C. ADMIN ROUTES (Butuh Bearer Token + Role Admin)
+	// ==========================================
+	admin := protected.Group("/admin")
+	admin.Use(mid.AdminMiddleware(dbClient))
+
+	// --- 1. Worker & Antrian Supplier Order ---
+	admin.GET("/worker/stats", adminHandler.WorkerStats)
+	admin.GET("/supplier-orders", adminHandler.ListSupplierOrders)
+	admin.GET("/supplier-orders/:id", adminHandler.SupplierOrderDetail)
+	admin.POST("/supplier-orders/:id/requeue", adminHandler.RequeueSupplierOrder)
+	admin.POST("/supplier-orders/:id/cancel", adminHandler.CancelSupplierOrder)
+	admin.POST("/suppliers/:id/pause", adminHandler.PauseSupplier)
+	admin.POST("/suppliers/:id/resume", adminHandler.ResumeSupplier)
+
+	// --- 2. Status Order (State Machine) ---
+	admin.PUT("/supplier-orders/:id/status", adminHandler.UpdateOrderStatus)
+	admin.GET("/supplier-orders/:id/history", adminHandler.OrderStatusHistory)
+
+	// --- 3. Artefak Kegagalan Scraper (screenshot, HTML, trace) ---
+	admin.GET("/supplier-orders/:id/artifacts", adminHandler.OrderArtifacts)
+	admin.GET("/supplier-orders/:id/artifacts/*", adminHandler.DownloadArtifact)
+
+	// --- 4. Konfigurasi Scraper per Supplier (selector, URL, timeout) ---
+	admin.GET("/suppliers/:id/scraper-config", supplierHandler.GetScraperConfig)
+	admin.PUT("/suppliers/:id/scraper-config", supplierHandler.UpdateScraperConfig)
+	admin.POST("/suppliers/:id/scraper-config/rollback", supplierHandler.RollbackScraperConfig)
+
+	// --- 5. Webhook Seller (Outbox & Redelivery) ---
+	admin.GET("/webhooks", adminHandler.ListWebhooks)
+	admin.GET("/webhooks/:id", adminHandler.WebhookDetail)
+	admin.POST("/webhooks/:id/redeliver", adminHandler.RedeliverWebhook)
+
+	// ==========================================
+	// D. SELLER ROUTES (Butuh API KEY)
 	// ==========================================
 	sellerGroup := v1.Group("/seller")
 	sellerGroup.Use(mid.SellerSecurityMiddleware(dbClient))
 	sellerGroup.GET("/profile", sellerHandler.GetProfile)
 	sellerGroup.PUT("/profile", sellerHandler.UpdateProfile)
+	sellerGroup.POST("/secret/rotate", sellerHandler.RotateSecret)
+	sellerGroup.POST("/telegram/link", sellerHandler.TelegramLink)
+	sellerGroup.DELETE("/telegram", sellerHandler.TelegramUnlink)
 	sellerGroup.GET("/products", sellerHandler.SellerProducts)
+	sellerGroup.GET("/check-destination", sellerHandler.CheckDestination)
 	sellerGroup.POST("/order", sellerHandler.SellerOrder)
 	sellerGroup.GET("/order/history", sellerHandler.HistoryOrder)
+	sellerGroup.GET("/order/:id", sellerHandler.OrderStatus)
+	sellerGroup.GET("/webhooks", sellerHandler.ListWebhooks)
+	sellerGroup.GET("/webhooks/:id", sellerHandler.WebhookDetail)
+	sellerGroup.POST("/webhooks/:id/redeliver", sellerHandler.RedeliverWebhook)
 	
 	sellerGroup.GET("/status", func(c echo.Context) error {
 		return c.JSON(200, echo.Map{"message": "Seller status endpoint"})
@@ -4,10 +4,19 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"time"
 
 	"gerbangapi/prisma/db"
 )
 
+// WebhookSecretGrace: lama secret lama masih ikut menandatangani webhook setelah
+// rotasi, agar webhook yang sedang dikirim / di-retry tetap lolos verifikasi
+var WebhookSecretGrace = 24 * time.Hour
+
+// ErrSecretRotated: secret sudah dirotasi request lain di saat yang sama
+var ErrSecretRotated = errors.New("secret sudah dirotasi, silakan coba lagi")
+
 func generateRandomKey(n int) string {
 	b := make([]byte, n)
 	rand.Read(b)
@@ -44,4 +53,53 @@ func ValidateApiKey(ctx context.Context, client *db.PrismaClient, apiKey string)
 	return client.APIKey.FindUnique(
 		db.APIKey.APIKey.Equals(apiKey),
 	).Exec(ctx)
-}
\ No newline at end of file
+}
+
+// RotateApiKeySecret mengganti secret API key. Secret lama disimpan sebagai
+// previous_secret dan tetap dipakai menandatangani webhook selama WebhookSecretGrace.
+func RotateApiKeySecret(ctx context.Context, client *db.PrismaClient, apiKey string) (*db.APIKeyModel, error) {
+	key, err := ValidateApiKey(ctx, client, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Conditional update: dua rotasi bersamaan tidak boleh menghilangkan secret lama
+	result, err := client.APIKey.FindMany(
+		db.APIKey.ID.Equals(key.ID),
+		db.APIKey.Secret.Equals(key.Secret),
+	).Update(
+		db.APIKey.Secret.Set(generateRandomKey(32)),
+		db.APIKey.PreviousSecret.Set(key.Secret),
+		db.APIKey.SecretRotatedAt.Set(time.Now()),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.Count == 0 {
+		return nil, ErrSecretRotated
+	}
+
+	return client.APIKey.FindUnique(db.APIKey.ID.Equals(key.ID)).Exec(ctx)
+}
+
+// WebhookSecrets mengembalikan secret untuk menandatangani webhook seller:
+// secret API key aktif terbaru, ditambah secret lama selama masa transisi rotasi
+func WebhookSecrets(ctx context.Context, client *db.PrismaClient, userID string) ([]string, error) {
+	key, err := client.APIKey.FindFirst(
+		db.APIKey.UserID.Equals(userID),
+		db.APIKey.IsActive.Equals(true),
+	).OrderBy(
+		db.APIKey.CreatedAt.Order(db.SortOrderDesc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := []string{key.Secret}
+	if previous, ok := key.PreviousSecret(); ok && previous != "" {
+		if rotatedAt, ok := key.SecretRotatedAt(); ok && time.Since(rotatedAt) < WebhookSecretGrace {
+			secrets = append(secrets, previous)
+		}
+	}
+	return secrets, nil
+}
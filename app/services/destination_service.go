@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gerbangapi/app/services/driver"
+	"gerbangapi/prisma/db"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	destinationCacheTTL        = 10 * time.Minute
+	destinationInvalidCacheTTL = time.Minute // Hasil "tidak ditemukan" disimpan lebih singkat
+)
+
+var (
+	// ErrDestinationNotFound: ID tujuan tidak dikenal oleh supplier
+	ErrDestinationNotFound = errors.New("user tujuan tidak ditemukan")
+
+	// ErrSupplierBusy: semua slot sesi akun supplier sedang dipakai worker
+	ErrSupplierBusy = errors.New("akun supplier sedang sibuk, silakan coba lagi")
+)
+
+// AccountLimiter membagi slot sesi paralel per akun supplier (max_concurrency)
+// dengan worker. Diimplementasikan oleh worker.Pool.
+type AccountLimiter interface {
+	// TryAcquireAccount mengambil slot akun tanpa menunggu. ok = false jika penuh.
+	TryAcquireAccount(supplier *db.SupplierModel) (release func(), ok bool)
+}
+
+// DestinationResult adalah hasil cek ID tujuan ke supplier
+type DestinationResult struct {
+	Destination string `json:"destination"`
+	Valid       bool   `json:"valid"`
+	Nickname    string `json:"nickname"`
+	SupplierID  string `json:"supplier_id"`
+	Cached      bool   `json:"cached"`
+}
+
+// DestinationService memvalidasi ID tujuan (buyer/player) lewat buyer lookup
+// driver supplier sebelum order diterima. Hasil disimpan di Redis sebentar.
+type DestinationService struct {
+	client  *db.PrismaClient
+	redis   *redis.Client
+	drivers *driver.Manager
+	limiter AccountLimiter
+}
+
+func NewDestinationService(client *db.PrismaClient, redisClient *redis.Client, drivers *driver.Manager, limiter AccountLimiter) *DestinationService {
+	return &DestinationService{client: client, redis: redisClient, drivers: drivers, limiter: limiter}
+}
+
+func (s *DestinationService) cacheKey(supplierID, destination string) string {
+	return fmt.Sprintf("destination:%s:%s", supplierID, destination)
+}
+
+// Check mengecek destination untuk produk. supplierID kosong = supplier produk.
+// Return ErrDestinationNotFound (bersama result Valid=false) jika ID tidak dikenal.
+func (s *DestinationService) Check(ctx context.Context, productID, supplierID, destination string) (*DestinationResult, error) {
+	product, err := s.client.Product.FindUnique(
+		db.Product.ID.Equals(productID),
+	).With(
+		db.Product.Recipe.Fetch().With(
+			db.ProductRecipe.SupplierProduct.Fetch(),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, errors.New("product tidak ditemukan")
+		}
+		return nil, err
+	}
+
+	if supplierID == "" {
+		supplierID = product.SupplierID
+	}
+
+	// 1. Cek cache
+	if raw, err := s.redis.Get(ctx, s.cacheKey(supplierID, destination)).Result(); err == nil {
+		var cached DestinationResult
+		if json.Unmarshal([]byte(raw), &cached) == nil {
+			cached.Cached = true
+			if !cached.Valid {
+				return &cached, ErrDestinationNotFound
+			}
+			return &cached, nil
+		}
+	}
+
+	// 2. Lookup ke supplier
+	supplier, err := s.client.Supplier.FindUnique(
+		db.Supplier.ID.Equals(supplierID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, errors.New("supplier tidak ditemukan")
+	}
+
+	// Beberapa driver (browser) butuh ID produk supplier untuk membuka form cek ID
+	supplierProductID := ""
+	if recipes := product.Recipe(); len(recipes) > 0 {
+		supplierProductID = recipes[0].SupplierProduct().SupplierProductID
+	}
+
+	drv, err := s.drivers.Get(supplier)
+	if err != nil {
+		return nil, err
+	}
+
+	// Login memakai slot akun yang sama dengan worker. Request seller tidak
+	// ikut mengantri: jika slot penuh langsung ditolak agar batas sesi akun
+	// tidak terlampaui dan order yang sedang diproses tidak terganggu.
+	release, ok := s.limiter.TryAcquireAccount(supplier)
+	if !ok {
+		return nil, ErrSupplierBusy
+	}
+	defer release()
+
+	session, err := drv.Login(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gagal login supplier: %w", err)
+	}
+	nickname, lookupErr := session.ValidateBuyer(ctx, destination, supplierProductID)
+
+	// ID tidak valid bukan masalah sesi, sesi tetap layak dipakai ulang
+	if errors.Is(lookupErr, driver.ErrInvalidBuyer) {
+		session.Release(nil)
+	} else {
+		session.Release(lookupErr)
+	}
+
+	result := &DestinationResult{
+		Destination: destination,
+		Valid:       lookupErr == nil,
+		Nickname:    nickname,
+		SupplierID:  supplierID,
+	}
+
+	switch {
+	case lookupErr == nil:
+		s.cache(ctx, result, destinationCacheTTL)
+		return result, nil
+	case errors.Is(lookupErr, driver.ErrInvalidBuyer):
+		s.cache(ctx, result, destinationInvalidCacheTTL)
+		return result, ErrDestinationNotFound
+	}
+	return nil, lookupErr
+}
+
+func (s *DestinationService) cache(ctx context.Context, result *DestinationResult, ttl time.Duration) {
+	payload, _ := json.Marshal(result)
+	if err := s.redis.Set(ctx, s.cacheKey(result.SupplierID, result.Destination), payload, ttl).Err(); err != nil {
+		log.Printf("⚠️ Gagal cache hasil cek destination: %v", err)
+	}
+}
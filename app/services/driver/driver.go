@@ -0,0 +1,231 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gerbangapi/app/services/scraper"
+	"gerbangapi/prisma/db"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrLoginRejected: kredensial ditolak supplier (tidak perlu retry)
+	ErrLoginRejected = errors.New("login ditolak supplier")
+
+	// ErrCredentialsMissing: username/password supplier belum diset di database
+	ErrCredentialsMissing = errors.New("kredensial supplier belum diset")
+
+	// ErrInvalidBuyer: ID tujuan (buyer/player) tidak dikenal supplier
+	ErrInvalidBuyer = errors.New("ID tujuan tidak valid")
+
+	// ErrPurchaseUncertain: pembelian mungkin sudah terjadi tapi tidak terkonfirmasi
+	ErrPurchaseUncertain = errors.New("status pembelian tidak pasti")
+
+	// ErrNotSupported: operasi tidak didukung oleh driver supplier ini
+	ErrNotSupported = errors.New("operasi tidak didukung driver")
+
+	// ErrNoDriver: tidak ada driver untuk code/type supplier
+	ErrNoDriver = errors.New("driver supplier tidak ditemukan")
+)
+
+// OrderRequest adalah satu baris pembelian ke supplier (satu bahan baku resep)
+type OrderRequest struct {
+	BuyerUID    string
+	ProductID   string // ID produk di sisi supplier (SupplierProduct.supplier_product_id)
+	Quantity    int
+	PaymentCode string
+
+	// OnUnit (opsional) dipanggil setiap satu unit berhasil dibeli
+	OnUnit func(unit Unit)
+}
+
+// Unit adalah hasil satu pembelian unit di supplier
+type Unit struct {
+	PaymentURL  string // Kosong jika supplier tidak memakai halaman pembayaran
+	SupplierRef string // Referensi transaksi di sisi supplier
+}
+
+// SupplierDriver adalah integrasi ke satu jenis supplier.
+// Driver dipilih berdasarkan Supplier.code lalu Supplier.type (lihat Register).
+type SupplierDriver interface {
+	// Login melakukan health check / login dan mengembalikan sesi siap pakai
+	Login(ctx context.Context) (Session, error)
+}
+
+// DefaultItemDelay: jeda antar bahan baku dalam satu order jika driver tidak
+// menentukan sendiri (lihat ItemDelay)
+const DefaultItemDelay = 2 * time.Second
+
+// ItemDelay mengembalikan jeda antar bahan baku untuk driver ini. Driver yang
+// jedanya bisa diatur (misal dari konfigurasi scraper) mengimplementasikan
+// ItemDelay() time.Duration.
+func ItemDelay(drv SupplierDriver) time.Duration {
+	if d, ok := drv.(interface{ ItemDelay() time.Duration }); ok {
+		return d.ItemDelay()
+	}
+	return DefaultItemDelay
+}
+
+// Session adalah sesi yang sudah login di supplier.
+// Release wajib dipanggil setelah selesai; err != nil menandakan sesi bermasalah.
+type Session interface {
+	// ValidateBuyer mengecek ID tujuan, return nama/nickname jika tersedia
+	ValidateBuyer(ctx context.Context, buyerUID, productID string) (string, error)
+
+	// PlaceOrder membeli sejumlah Quantity. Jika gagal di tengah jalan, unit
+	// yang sudah sukses tetap dikembalikan bersama error.
+	PlaceOrder(ctx context.Context, req OrderRequest) ([]Unit, error)
+
+	// CheckStatus mengecek status pembayaran/pengiriman satu unit di supplier.
+	// Return salah satu konstanta Payment*.
+	CheckStatus(ctx context.Context, unit Unit) (string, error)
+
+	Release(err error)
+}
+
+// Status pembayaran satu unit (SupplierTransaction.status)
+const (
+	PaymentAwaiting  = "awaiting_payment" // URL pembayaran sudah terbit, belum dibayar
+	PaymentPaid      = "paid"             // Sudah dibayar, item belum terkonfirmasi terkirim
+	PaymentDelivered = "delivered"        // Item sudah terkirim ke player
+	PaymentExpired   = "expired"          // Batas waktu pembayaran habis
+)
+
+type artifactKeyCtx struct{}
+
+// WithArtifactKey menandai context dengan ID supplier order yang sedang diproses,
+// dipakai driver sebagai folder artefak jika terjadi kegagalan
+func WithArtifactKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, artifactKeyCtx{}, key)
+}
+
+// ArtifactKey membaca ID supplier order dari context (kosong = artefak tidak disimpan)
+func ArtifactKey(ctx context.Context) string {
+	key, _ := ctx.Value(artifactKeyCtx{}).(string)
+	return key
+}
+
+// ArtifactPath mengembalikan lokasi artefak kegagalan yang terlampir di error
+// (screenshot, HTML, trace), kosong jika driver tidak menyimpan artefak
+func ArtifactPath(err error) string {
+	var captured interface{ ArtifactPath() string }
+	if errors.As(err, &captured) {
+		return captured.ArtifactPath()
+	}
+	return ""
+}
+
+// SupplierChangedChannel adalah channel Redis pub/sub yang dikirim saat data
+// supplier (kredensial, konfigurasi scraper, dll) berubah, agar worker di semua
+// replica langsung memuat ulang supplier tanpa menunggu rescan berkala
+const SupplierChangedChannel = "supplier:changed"
+
+// NotifySupplierChanged memberi tahu worker bahwa supplier berubah (best-effort)
+func NotifySupplierChanged(ctx context.Context, redisClient *redis.Client, supplierID string) {
+	if err := redisClient.Publish(ctx, SupplierChangedChannel, supplierID).Err(); err != nil {
+		log.Printf("⚠️ Gagal publish perubahan supplier %s: %v", supplierID, err)
+	}
+}
+
+// Deps adalah resource bersama yang bisa dipakai driver
+type Deps struct {
+	Redis    *redis.Client
+	Sessions *scraper.SessionPool
+}
+
+// Factory membuat driver untuk satu supplier
+type Factory func(supplier *db.SupplierModel, deps *Deps) (SupplierDriver, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register mendaftarkan driver untuk code atau type supplier (case-insensitive)
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(name)] = factory
+}
+
+// lookup mencari driver berdasarkan Supplier.code, lalu Supplier.type
+func lookup(supplier *db.SupplierModel) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if f, ok := registry[strings.ToLower(supplier.Code)]; ok {
+		return f, true
+	}
+	f, ok := registry[strings.ToLower(supplier.Type)]
+	return f, ok
+}
+
+// Manager menyimpan driver per supplier dan membuat ulang driver jika data
+// supplier (kredensial, dll) berubah.
+type Manager struct {
+	deps *Deps
+
+	mu      sync.Mutex
+	drivers map[string]*cachedDriver
+}
+
+type cachedDriver struct {
+	driver    SupplierDriver
+	updatedAt int64
+}
+
+func NewManager(redisClient *redis.Client) *Manager {
+	return &Manager{
+		deps: &Deps{
+			Redis:    redisClient,
+			Sessions: scraper.NewSessionPool(redisClient),
+		},
+		drivers: make(map[string]*cachedDriver),
+	}
+}
+
+// Supports mengecek apakah ada driver untuk supplier ini
+func (m *Manager) Supports(supplier *db.SupplierModel) bool {
+	_, ok := lookup(supplier)
+	return ok
+}
+
+// Get mengembalikan driver untuk supplier
+func (m *Manager) Get(supplier *db.SupplierModel) (SupplierDriver, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	version := supplier.UpdatedAt.UnixNano()
+	if cached, ok := m.drivers[supplier.ID]; ok && cached.updatedAt == version {
+		return cached.driver, nil
+	}
+
+	factory, ok := lookup(supplier)
+	if !ok {
+		return nil, fmt.Errorf("%w (code: %s, type: %s)", ErrNoDriver, supplier.Code, supplier.Type)
+	}
+
+	d, err := factory(supplier, m.deps)
+	if err != nil {
+		return nil, err
+	}
+	m.drivers[supplier.ID] = &cachedDriver{driver: d, updatedAt: version}
+	return d, nil
+}
+
+// Artifacts mengembalikan penyimpanan artefak kegagalan driver browser
+func (m *Manager) Artifacts() *scraper.ArtifactStore {
+	return m.deps.Sessions.Artifacts
+}
+
+// Close menutup resource bersama (sesi browser) milik semua driver
+func (m *Manager) Close() {
+	m.deps.Sessions.Close()
+}
@@ -0,0 +1,258 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"gerbangapi/app/services/scraper"
+	"gerbangapi/prisma/db"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Mode eksekusi order MitraHiggs (Supplier.execution_mode)
+const (
+	ModeBrowser = "browser" // klik DOM lewat Playwright
+	ModeHTTP    = "http"    // panggil endpoint trade/* langsung
+)
+
+func init() {
+	Register("MH_OFFICIAL", newMitraHiggsDriver)
+	Register("mitrahiggs", newMitraHiggsDriver)
+}
+
+// mitraHiggsDriver menjalankan order lewat browser (Playwright) di mitrahiggs.com.
+// Jika Supplier.execution_mode = "http", dipakai mitraHiggsHTTPDriver.
+type mitraHiggsDriver struct {
+	username string
+	password string
+	config   *scraper.MitraHiggsConfig
+	sessions *scraper.SessionPool
+}
+
+func newMitraHiggsDriver(supplier *db.SupplierModel, deps *Deps) (SupplierDriver, error) {
+	username, _ := supplier.Username()
+	password, _ := supplier.Password()
+
+	// Selector, URL & timeout scraper per supplier; driver dibuat ulang oleh
+	// Manager setiap data supplier berubah, sehingga konfigurasi baru langsung dipakai
+	rawConfig, _ := supplier.ScraperConfig()
+	baseURL, _ := supplier.BaseURL()
+	config, err := scraper.ParseMitraHiggsConfig(rawConfig, baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("supplier %s: %w", supplier.Code, err)
+	}
+	config.Version = supplier.ScraperConfigVersion
+
+	base := mitraHiggsDriver{
+		username: username,
+		password: password,
+		config:   config,
+		sessions: deps.Sessions,
+	}
+
+	if supplier.ExecutionMode == ModeHTTP {
+		return &mitraHiggsHTTPDriver{mitraHiggsDriver: base, baseURL: baseURL, redis: deps.Redis}, nil
+	}
+	return &base, nil
+}
+
+func (d *mitraHiggsDriver) Login(ctx context.Context) (Session, error) {
+	if d.username == "" || d.password == "" {
+		return nil, ErrCredentialsMissing
+	}
+
+	svc, err := d.sessions.Acquire(ArtifactKey(ctx), d.config, d.username, d.password)
+	if err != nil {
+		return nil, mapMitraHiggsError(err)
+	}
+	return &mitraHiggsSession{svc: svc, pool: d.sessions}, nil
+}
+
+type mitraHiggsSession struct {
+	svc  *scraper.MitraHiggsService
+	pool *scraper.SessionPool
+}
+
+func (s *mitraHiggsSession) ValidateBuyer(ctx context.Context, buyerUID, productID string) (string, error) {
+	name, err := s.svc.ValidateBuyer(buyerUID, productID)
+	return name, mapMitraHiggsError(err)
+}
+
+func (s *mitraHiggsSession) PlaceOrder(ctx context.Context, req OrderRequest) ([]Unit, error) {
+	var units []Unit
+	_, err := s.svc.PlaceOrder(ctx, req.BuyerUID, req.ProductID, req.Quantity, req.PaymentCode, func(paymentURL string) {
+		unit := Unit{PaymentURL: paymentURL, SupplierRef: parseMitraHiggsRef(paymentURL)}
+		units = append(units, unit)
+		if req.OnUnit != nil {
+			req.OnUnit(unit)
+		}
+	})
+	if err != nil && !errors.Is(err, scraper.ErrInvalidPlayer) {
+		err = s.pool.CaptureFailure(s.svc, err)
+	}
+	return units, mapMitraHiggsError(err)
+}
+
+// parseMitraHiggsRef mengambil nomor transaksi dari URL pembayaran MitraHiggs.
+// Dicari dari query parameter yang umum dipakai payment gateway, lalu segmen
+// path terakhir sebagai fallback.
+func parseMitraHiggsRef(paymentURL string) string {
+	u, err := url.Parse(paymentURL)
+	if err != nil {
+		return ""
+	}
+
+	query := u.Query()
+	for _, key := range []string{"orderId", "order_id", "orderNo", "trxId", "trx_id", "transactionId", "reference", "ref"} {
+		if v := query.Get(key); v != "" {
+			return v
+		}
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return segments[len(segments)-1]
+}
+
+// CheckStatus membuka halaman pembayaran unit. MitraHiggs mengirim koin otomatis
+// setelah pembayaran sukses, sehingga pembayaran sukses dianggap delivered.
+func (s *mitraHiggsSession) CheckStatus(ctx context.Context, unit Unit) (string, error) {
+	if unit.PaymentURL == "" {
+		return "", ErrNotSupported
+	}
+
+	state, err := s.svc.CheckPayment(unit.PaymentURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch state {
+	case scraper.PaymentSuccess:
+		return PaymentDelivered, nil
+	case scraper.PaymentExpired:
+		return PaymentExpired, nil
+	}
+	return PaymentAwaiting, nil
+}
+
+func (s *mitraHiggsSession) Release(err error) {
+	s.pool.Release(s.svc, err)
+}
+
+// mapMitraHiggsError membungkus error scraper dengan error umum driver
+// (error asli tetap bisa dicek dengan errors.Is)
+func mapMitraHiggsError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, scraper.ErrLoginRejected):
+		return fmt.Errorf("%w: %w", ErrLoginRejected, err)
+	case errors.Is(err, scraper.ErrInvalidPlayer):
+		return fmt.Errorf("%w: %w", ErrInvalidBuyer, err)
+	case errors.Is(err, scraper.ErrPurchaseUncertain):
+		return fmt.Errorf("%w: %w", ErrPurchaseUncertain, err)
+	}
+	return err
+}
+
+// ItemDelay: jeda antar bahan baku dari timeouts.item_delay konfigurasi scraper
+func (d *mitraHiggsDriver) ItemDelay() time.Duration {
+	return time.Duration(d.config.Timeouts.ItemDelay) * time.Millisecond
+}
+
+// mitraHiggsHTTPDriver memanggil endpoint trade/queryBuyer & trade/sellCard
+// langsung. Browser hanya dipakai untuk login saat cookie tidak ada / expired.
+type mitraHiggsHTTPDriver struct {
+	mitraHiggsDriver
+	baseURL string
+	redis   *redis.Client
+}
+
+func (d *mitraHiggsHTTPDriver) Login(ctx context.Context) (Session, error) {
+	if d.username == "" || d.password == "" {
+		return nil, ErrCredentialsMissing
+	}
+
+	client := scraper.NewMitraHiggsHTTPClient(d.baseURL, d.redis)
+	client.TradePath = d.config.Paths.Trade
+
+	// Health check: buka halaman toko dengan cookie tersimpan (redirect = sesi habis)
+	err := client.LoadCookies(ctx, d.username)
+	if err == nil {
+		err = client.CheckSession(ctx)
+	}
+
+	if errors.Is(err, scraper.ErrSessionExpired) {
+		log.Printf("🍪 Sesi HTTP MitraHiggs %s expired, login ulang lewat browser...", d.username)
+		if err := d.refreshCookies(ctx); err != nil {
+			return nil, err
+		}
+		if err := client.LoadCookies(ctx, d.username); err != nil {
+			return nil, err
+		}
+		err = client.CheckSession(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &mitraHiggsHTTPSession{client: client}, nil
+}
+
+// refreshCookies mengambil sesi browser yang sudah login lalu menyimpan cookie-nya
+// ke Redis. Sesi pool yang masih hangat tidak login ulang (sehingga tidak
+// menyimpan cookie), jadi cookie selalu disimpan eksplisit di sini.
+func (d *mitraHiggsHTTPDriver) refreshCookies(ctx context.Context) error {
+	svc, err := d.sessions.Acquire(ArtifactKey(ctx), d.config, d.username, d.password)
+	if err != nil {
+		return mapMitraHiggsError(err)
+	}
+	svc.SaveSession(ctx, d.username)
+	d.sessions.Release(svc, nil)
+	return nil
+}
+
+type mitraHiggsHTTPSession struct {
+	client *scraper.MitraHiggsHTTPClient
+}
+
+func (s *mitraHiggsHTTPSession) ValidateBuyer(ctx context.Context, buyerUID, productID string) (string, error) {
+	buyer, err := s.client.QueryBuyer(ctx, buyerUID)
+	if err != nil {
+		return "", mapMitraHiggsError(err)
+	}
+	return buyer.NickName, nil
+}
+
+// PlaceOrder memanggil sellCard sebanyak Quantity. Item langsung terkirim ke
+// player (tidak ada URL pembayaran), referensi berisi item, player & waktu kirim.
+func (s *mitraHiggsHTTPSession) PlaceOrder(ctx context.Context, req OrderRequest) ([]Unit, error) {
+	var units []Unit
+
+	for i := 1; i <= req.Quantity; i++ {
+		if err := ctx.Err(); err != nil {
+			return units, fmt.Errorf("sellCard dibatalkan sebelum loop %d: %w", i, err)
+		}
+		if err := s.client.SellCard(ctx, req.ProductID, req.BuyerUID); err != nil {
+			return units, mapMitraHiggsError(fmt.Errorf("sellCard (Loop %d): %w", i, err))
+		}
+		unit := Unit{SupplierRef: fmt.Sprintf("sellCard:%s:%s:%d", req.ProductID, req.BuyerUID, time.Now().UnixMilli())}
+		units = append(units, unit)
+		if req.OnUnit != nil {
+			req.OnUnit(unit)
+		}
+	}
+	return units, nil
+}
+
+// CheckStatus: sellCard langsung mengirim item, tidak ada status pembayaran
+func (s *mitraHiggsHTTPSession) CheckStatus(ctx context.Context, unit Unit) (string, error) {
+	return PaymentDelivered, nil
+}
+
+func (s *mitraHiggsHTTPSession) Release(err error) {}
@@ -0,0 +1,26 @@
+package driver
+
+import "testing"
+
+func TestParseMitraHiggsRef(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://mitrahiggs.com/pay/index?orderId=MH00000001", "MH00000001"},
+		{"https://pay.example.com/checkout?order_id=A1&ref=B2", "A1"},
+		{"https://pay.example.com/checkout?trxId=T-99", "T-99"},
+		{"https://pay.example.com/checkout?reference=R1", "R1"},
+		{"https://pay.example.com/invoice/INV123", "INV123"},
+		{"https://pay.example.com/invoice/INV123/", "INV123"},
+		{"https://pay.example.com/invoice/INV123?lang=id", "INV123"},
+		{"https://pay.example.com", ""},
+		{"://bukan-url", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseMitraHiggsRef(tt.url); got != tt.want {
+			t.Errorf("parseMitraHiggsRef(%q) = %q, harus %q", tt.url, got, tt.want)
+		}
+	}
+}
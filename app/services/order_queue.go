@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// OrderQueue adalah antrian supplier order berbasis Redis (reliable queue).
+// Setiap supplier punya list "pending" sendiri. Worker memindahkan ID secara
+// atomik ke list "processing" (BLMOVE) dan baru menghapusnya setelah Ack,
+// sehingga job yang sedang jalan tidak hilang jika proses mati di tengah jalan.
+type OrderQueue struct {
+	Redis *redis.Client
+}
+
+func NewOrderQueue(redisClient *redis.Client) *OrderQueue {
+	return &OrderQueue{Redis: redisClient}
+}
+
+func (q *OrderQueue) pendingKey(supplierID string) string {
+	return fmt.Sprintf("queue:supplier_order:%s:pending", supplierID)
+}
+
+func (q *OrderQueue) processingKey(supplierID string) string {
+	return fmt.Sprintf("queue:supplier_order:%s:processing", supplierID)
+}
+
+func (q *OrderQueue) delayedKey(supplierID string) string {
+	return fmt.Sprintf("queue:supplier_order:%s:delayed", supplierID)
+}
+
+// Enqueue memasukkan supplier order ID ke antrian supplier terkait
+func (q *OrderQueue) Enqueue(ctx context.Context, supplierID, orderID string) error {
+	return q.Redis.LPush(ctx, q.pendingKey(supplierID), orderID).Err()
+}
+
+// EnqueueAt menjadwalkan job untuk diproses pada waktu tertentu (retry/backoff).
+// Job disimpan di sorted set dan dipindah ke antrian utama oleh PromoteDue.
+func (q *OrderQueue) EnqueueAt(ctx context.Context, supplierID, orderID string, at time.Time) error {
+	return q.Redis.ZAdd(ctx, q.delayedKey(supplierID), redis.Z{
+		Score:  float64(at.Unix()),
+		Member: orderID,
+	}).Err()
+}
+
+// PromoteDue memindahkan job terjadwal yang sudah jatuh tempo ke antrian utama
+func (q *OrderQueue) PromoteDue(ctx context.Context, supplierID string) (int, error) {
+	due, err := q.Redis.ZRangeByScore(ctx, q.delayedKey(supplierID), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for _, orderID := range due {
+		// ZRem sebagai "lock": hanya satu proses yang berhasil memindahkan job
+		removed, err := q.Redis.ZRem(ctx, q.delayedKey(supplierID), orderID).Result()
+		if err != nil {
+			return promoted, err
+		}
+		if removed == 0 {
+			continue
+		}
+		if err := q.Enqueue(ctx, supplierID, orderID); err != nil {
+			return promoted, err
+		}
+		promoted++
+	}
+	return promoted, nil
+}
+
+// EnqueueIfAbsent hanya memasukkan ID jika belum ada di list pending/processing
+// maupun jadwal retry. Dipakai oleh fallback scan DB agar tidak membuat job dobel.
+func (q *OrderQueue) EnqueueIfAbsent(ctx context.Context, supplierID, orderID string) (bool, error) {
+	_, err := q.Redis.ZScore(ctx, q.delayedKey(supplierID), orderID).Result()
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	for _, key := range []string{q.pendingKey(supplierID), q.processingKey(supplierID)} {
+		_, err := q.Redis.LPos(ctx, key, orderID, redis.LPosArgs{}).Result()
+		if err == nil {
+			return false, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return false, err
+		}
+	}
+
+	if err := q.Enqueue(ctx, supplierID, orderID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Dequeue menunggu (blocking) sampai ada job atau timeout habis.
+// Return "" tanpa error jika timeout.
+func (q *OrderQueue) Dequeue(ctx context.Context, supplierID string, timeout time.Duration) (string, error) {
+	orderID, err := q.Redis.BLMove(ctx, q.pendingKey(supplierID), q.processingKey(supplierID), "RIGHT", "LEFT", timeout).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", err
+	}
+	return orderID, nil
+}
+
+// Ack menandai job selesai diproses (sukses maupun gagal)
+func (q *OrderQueue) Ack(ctx context.Context, supplierID, orderID string) error {
+	return q.Redis.LRem(ctx, q.processingKey(supplierID), 1, orderID).Err()
+}
+
+// Requeue mengembalikan job dari list processing ke list pending
+func (q *OrderQueue) Requeue(ctx context.Context, supplierID, orderID string) error {
+	pipe := q.Redis.TxPipeline()
+	pipe.LRem(ctx, q.processingKey(supplierID), 1, orderID)
+	pipe.LPush(ctx, q.pendingKey(supplierID), orderID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Remove membuang job dari list pending dan jadwal retry (misal order dibatalkan).
+// Job yang sudah terambil worker tidak disentuh; klaimnya akan gagal dengan sendirinya.
+func (q *OrderQueue) Remove(ctx context.Context, supplierID, orderID string) error {
+	pipe := q.Redis.TxPipeline()
+	pipe.LRem(ctx, q.pendingKey(supplierID), 0, orderID)
+	pipe.ZRem(ctx, q.delayedKey(supplierID), orderID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Processing mengembalikan daftar job yang sudah diambil worker tapi belum di-Ack
+func (q *OrderQueue) Processing(ctx context.Context, supplierID string) ([]string, error) {
+	return q.Redis.LRange(ctx, q.processingKey(supplierID), 0, -1).Result()
+}
+
+// Depth mengembalikan jumlah job yang masih menunggu di antrian
+func (q *OrderQueue) Depth(ctx context.Context, supplierID string) (int64, error) {
+	return q.Redis.LLen(ctx, q.pendingKey(supplierID)).Result()
+}
+
+// Delayed mengembalikan jumlah job yang menunggu jadwal retry
+func (q *OrderQueue) Delayed(ctx context.Context, supplierID string) (int64, error) {
+	return q.Redis.ZCard(ctx, q.delayedKey(supplierID)).Result()
+}
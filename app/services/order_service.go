@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 
+	"gerbangapi/app/services/orderstate"
 	"gerbangapi/prisma/db"
 )
 
@@ -16,10 +18,12 @@ type MixingItem struct {
 
 type OrderService struct {
 	client *db.PrismaClient
+	queue  *OrderQueue
+	states *orderstate.Machine
 }
 
-func NewOrderService(client *db.PrismaClient) *OrderService {
-	return &OrderService{client: client}
+func NewOrderService(client *db.PrismaClient, queue *OrderQueue) *OrderService {
+	return &OrderService{client: client, queue: queue, states: orderstate.New(client)}
 }
 
 // 1) Build Supplier Items
@@ -86,7 +90,7 @@ func (s *OrderService) CreateSupplierOrderFromInternal(
 		),
 
 		// 3. Scalar Fields
-		db.SupplierOrder.Status.Set("pending"),
+		db.SupplierOrder.Status.Set(orderstate.Pending),
 	).Exec(ctx)
 
 	if err != nil {
@@ -115,6 +119,8 @@ func (s *OrderService) CreateSupplierOrderFromInternal(
 		}
 	}
 
+	s.states.Created(ctx, orderstate.TypeSupplierOrder, order.ID, orderstate.Pending, "system")
+
 	return order, nil
 }
 
@@ -142,7 +148,20 @@ func (s *OrderService) ProcessInternalOrder(
 	}
 
 	// Oper supplierID ke fungsi creation
-	return s.CreateSupplierOrderFromInternal(ctx, *internalOrder, supplierID)
+	supplierOrder, err := s.CreateSupplierOrderFromInternal(ctx, *internalOrder, supplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Masukkan ke antrian worker. Jika Redis sedang bermasalah, order tetap
+	// tersimpan 'pending' dan akan diambil oleh fallback scan DB di worker.
+	if s.queue != nil {
+		if err := s.queue.Enqueue(ctx, supplierOrder.SupplierID, supplierOrder.ID); err != nil {
+			log.Printf("⚠️ Gagal enqueue supplier order %s: %v", supplierOrder.ID, err)
+		}
+	}
+
+	return supplierOrder, nil
 }
 
 // 4) GET HISTORY BY USER ID
@@ -152,7 +171,9 @@ func (s *OrderService) GetOrderHistory(ctx context.Context, userID string) ([]db
 		db.InternalOrder.UserID.Equals(userID),
 	).With(
 		db.InternalOrder.Product.Fetch(),
-		db.InternalOrder.SupplierOrders.Fetch(),
+		db.InternalOrder.SupplierOrders.Fetch().With(
+			SupplierOrderWithTransactions(), // Ledger transaksi per unit
+		),
 		db.InternalOrder.PaymentType.Fetch(), // [BARU] Tambahkan Fetch PaymentType agar datanya bisa dibaca handler
 	).Exec(ctx) // [FIX] OrderBy dihapus untuk mencegah panic jika generate belum update
 
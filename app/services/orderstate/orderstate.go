@@ -0,0 +1,223 @@
+package orderstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"gerbangapi/prisma/db"
+)
+
+// Status order (dipakai bersama InternalOrder & SupplierOrder)
+const (
+	Pending         = "pending"          // Menunggu diproses worker
+	Processing      = "processing"       // Sedang diproses worker
+	AwaitingPayment = "awaiting_payment" // URL pembayaran terbit, menunggu dibayar
+	Paid            = "paid"             // Sudah dibayar, menunggu item terkirim
+	Delivered       = "delivered"        // Item terkirim ke tujuan
+	Expired         = "expired"          // Batas waktu pembayaran habis
+	Failed          = "failed"           // Gagal, tidak ada pembelian di supplier
+	Partial         = "partial"          // Sebagian unit terbeli / terbayar
+	ManualReview    = "manual_review"    // Macet di tengah pembelian, perlu dicek admin
+	Cancelled       = "cancelled"        // Dibatalkan admin sebelum diklaim worker
+	Refunded        = "refunded"         // Dana pembeli dikembalikan (dicatat admin)
+	Success         = "success"          // Legacy: status sukses sebelum ada tracking pembayaran
+)
+
+// Jenis order yang dicatat di OrderStatusHistory.order_type
+const (
+	TypeInternalOrder = "internal_order"
+	TypeSupplierOrder = "supplier_order"
+)
+
+var (
+	// ErrIllegalTransition: perpindahan status tidak diizinkan (butuh Force oleh admin)
+	ErrIllegalTransition = errors.New("transisi status tidak diizinkan")
+
+	// ErrUnknownStatus: status tujuan tidak dikenal
+	ErrUnknownStatus = errors.New("status tidak dikenal")
+)
+
+// transitions adalah daftar perpindahan status yang diizinkan.
+// InternalOrder tidak melewati 'processing', sehingga pending bisa langsung
+// ke status hasil. failed -> pending hanya dipakai requeue oleh admin.
+// success (legacy) hanya boleh maju ke alur pembayaran agar tidak sukses selamanya.
+var transitions = map[string][]string{
+	Pending:         {Processing, AwaitingPayment, Delivered, Failed, Partial, Cancelled},
+	Processing:      {Pending, AwaitingPayment, Delivered, Failed, Partial, ManualReview},
+	AwaitingPayment: {Paid, Delivered, Expired, Partial},
+	Paid:            {Delivered, Refunded},
+	ManualReview:    {Refunded},
+	Delivered:       {},
+	Expired:         {},
+	Failed:          {Pending},
+	Cancelled:       {},
+	Refunded:        {},
+	Partial:         {Refunded},
+	Success:         {AwaitingPayment, Expired}, // Legacy: dimasukkan ke alur pembayaran / expiry
+}
+
+// IsValid mengecek apakah status dikenal state machine
+func IsValid(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition mengecek apakah from -> to diizinkan tanpa override admin
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Machine adalah satu-satunya jalur untuk mengubah status order. Setiap
+// perubahan dilakukan dengan conditional update (status lama harus cocok)
+// dan dicatat di OrderStatusHistory.
+type Machine struct {
+	client *db.PrismaClient
+}
+
+func New(client *db.PrismaClient) *Machine {
+	return &Machine{client: client}
+}
+
+// SupplierChange adalah permintaan perubahan status SupplierOrder
+type SupplierChange struct {
+	OrderID string
+	From    string // Status saat ini yang diharapkan; kosong = dibaca dari DB
+	To      string
+	Reason  string
+	Actor   string // Contoh: "worker:<id>", "seller:<user_id>", "admin:<user_id>"
+	Force   bool   // Override admin: abaikan tabel transisi
+
+	Where []db.SupplierOrderWhereParam // Syarat tambahan (misal worker pemegang klaim)
+	Set   []db.SupplierOrderSetParam   // Field lain yang di-update bersamaan
+}
+
+// SupplierOrder mengubah status supplier order. Return false (tanpa error) jika
+// status sudah diubah proses lain / syarat tambahan tidak terpenuhi.
+func (m *Machine) SupplierOrder(ctx context.Context, c SupplierChange) (bool, error) {
+	if c.From == "" {
+		order, err := m.client.SupplierOrder.FindUnique(db.SupplierOrder.ID.Equals(c.OrderID)).Exec(ctx)
+		if err != nil {
+			return false, err
+		}
+		c.From = order.Status
+	}
+	if err := check(c.From, c.To, c.Force); err != nil {
+		return false, err
+	}
+
+	where := append([]db.SupplierOrderWhereParam{
+		db.SupplierOrder.ID.Equals(c.OrderID),
+		db.SupplierOrder.Status.Equals(c.From),
+	}, c.Where...)
+	set := append([]db.SupplierOrderSetParam{db.SupplierOrder.Status.Set(c.To)}, c.Set...)
+
+	result, err := m.client.SupplierOrder.FindMany(where...).Update(set...).Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	if result.Count == 0 {
+		return false, nil
+	}
+
+	m.record(ctx, TypeSupplierOrder, c.OrderID, c.From, c.To, c.Reason, c.Actor, c.Force)
+	return true, nil
+}
+
+// InternalChange adalah permintaan perubahan status InternalOrder
+type InternalChange struct {
+	OrderID string
+	From    string // Kosong = dibaca dari DB
+	To      string
+	Reason  string
+	Actor   string
+	Force   bool
+}
+
+// InternalOrder mengubah status internal order (order milik seller)
+func (m *Machine) InternalOrder(ctx context.Context, c InternalChange) (bool, error) {
+	if c.From == "" {
+		order, err := m.client.InternalOrder.FindUnique(db.InternalOrder.ID.Equals(c.OrderID)).Exec(ctx)
+		if err != nil {
+			return false, err
+		}
+		c.From = order.Status
+	}
+	if c.From == c.To {
+		return false, nil
+	}
+	if err := check(c.From, c.To, c.Force); err != nil {
+		return false, err
+	}
+
+	result, err := m.client.InternalOrder.FindMany(
+		db.InternalOrder.ID.Equals(c.OrderID),
+		db.InternalOrder.Status.Equals(c.From),
+	).Update(
+		db.InternalOrder.Status.Set(c.To),
+	).Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	if result.Count == 0 {
+		return false, nil
+	}
+
+	m.record(ctx, TypeInternalOrder, c.OrderID, c.From, c.To, c.Reason, c.Actor, c.Force)
+	return true, nil
+}
+
+// Created mencatat status awal order yang baru dibuat
+func (m *Machine) Created(ctx context.Context, orderType, orderID, status, actor string) {
+	m.record(ctx, orderType, orderID, "", status, "order dibuat", actor, false)
+}
+
+// History mengembalikan riwayat status order, urut dari yang paling lama
+func (m *Machine) History(ctx context.Context, orderType, orderID string) ([]db.OrderStatusHistoryModel, error) {
+	return m.client.OrderStatusHistory.FindMany(
+		db.OrderStatusHistory.OrderType.Equals(orderType),
+		db.OrderStatusHistory.OrderID.Equals(orderID),
+	).OrderBy(
+		db.OrderStatusHistory.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+func check(from, to string, force bool) error {
+	if !IsValid(to) {
+		return fmt.Errorf("%w: %s", ErrUnknownStatus, to)
+	}
+	if !force && !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+func (m *Machine) record(ctx context.Context, orderType, orderID, from, to, reason, actor string, force bool) {
+	if force {
+		reason = "[OVERRIDE] " + reason
+	}
+
+	params := []db.OrderStatusHistorySetParam{
+		db.OrderStatusHistory.Reason.Set(reason),
+	}
+	if from != "" {
+		params = append(params, db.OrderStatusHistory.FromStatus.Set(from))
+	}
+
+	_, err := m.client.OrderStatusHistory.CreateOne(
+		db.OrderStatusHistory.OrderType.Set(orderType),
+		db.OrderStatusHistory.OrderID.Set(orderID),
+		db.OrderStatusHistory.ToStatus.Set(to),
+		db.OrderStatusHistory.Actor.Set(actor),
+		params...,
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("⚠️ Gagal mencatat riwayat status %s %s (%s -> %s): %v", orderType, orderID, from, to, err)
+	}
+}
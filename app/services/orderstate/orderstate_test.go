@@ -0,0 +1,51 @@
+package orderstate
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{Pending, Processing, true},
+		{Pending, Cancelled, true},
+		{Pending, ManualReview, false},
+		{Processing, Pending, true}, // retry
+		{Processing, AwaitingPayment, true},
+		{Processing, ManualReview, true},
+		{Processing, Cancelled, false},
+		{AwaitingPayment, Paid, true},
+		{AwaitingPayment, Expired, true},
+		{AwaitingPayment, Processing, false},
+		{Paid, Delivered, true},
+		{Paid, AwaitingPayment, false},
+		{Failed, Pending, true}, // requeue admin
+		{ManualReview, Refunded, true},
+		{ManualReview, Pending, false},
+		{Partial, Refunded, true},
+		{Delivered, Refunded, false},
+		{Expired, Paid, false},
+		{Success, AwaitingPayment, true}, // order legacy
+		{Success, Expired, true},
+		{Success, Delivered, false},
+		{"tidak_dikenal", Pending, false},
+		{Pending, "tidak_dikenal", false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, harus %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	for _, status := range []string{Pending, Processing, AwaitingPayment, Paid, Delivered, Expired, Failed, Partial, ManualReview, Cancelled, Refunded, Success} {
+		if !IsValid(status) {
+			t.Errorf("IsValid(%s) = false", status)
+		}
+	}
+	if IsValid("sukses") {
+		t.Error("IsValid(sukses) = true, status tidak dikenal harus ditolak")
+	}
+}
@@ -0,0 +1,187 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+var ErrArtifactNotFound = errors.New("artefak tidak ditemukan")
+
+// ArtifactStore menyimpan artefak kegagalan scraper (screenshot, HTML halaman,
+// Playwright trace) di disk lokal, dikelompokkan per supplier order:
+// <Dir>/<supplier order ID>/<waktu gagal>/{screenshot.png,page.html,trace.zip,error.txt}
+type ArtifactStore struct {
+	Dir string
+}
+
+// Artifact adalah satu file artefak; Path relatif terhadap folder order
+type Artifact struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ArtifactError membungkus error scraper beserta lokasi artefaknya
+// (relatif terhadap ArtifactStore.Dir)
+type ArtifactError struct {
+	Err  error
+	Path string
+}
+
+func (e *ArtifactError) Error() string        { return e.Err.Error() }
+func (e *ArtifactError) Unwrap() error        { return e.Err }
+func (e *ArtifactError) ArtifactPath() string { return e.Path }
+
+func NewArtifactStore() *ArtifactStore {
+	dir := os.Getenv("SCRAPER_ARTIFACT_DIR")
+	if dir == "" {
+		dir = "storage/artifacts"
+	}
+	return &ArtifactStore{Dir: dir}
+}
+
+// Capture menyimpan screenshot full page, HTML, dan trace sejak chunk terakhir
+// dari sesi browser. Dilakukan best-effort: file yang gagal diambil dilewati.
+// Return error asli yang dibungkus ArtifactError (atau error asli jika key kosong).
+func (a *ArtifactStore) Capture(svc *MitraHiggsService, key string, cause error) error {
+	if a == nil || svc == nil || cause == nil || !validArtifactKey(key) {
+		return cause
+	}
+
+	rel := filepath.Join(key, time.Now().Format("20060102-150405.000"))
+	dir := filepath.Join(a.Dir, rel)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("⚠️ Gagal membuat folder artefak %s: %v", dir, err)
+		return cause
+	}
+
+	os.WriteFile(filepath.Join(dir, "error.txt"), []byte(cause.Error()+"\n"), 0o644)
+
+	if svc.Page != nil && !svc.Page.IsClosed() {
+		if _, err := svc.Page.Screenshot(playwright.PageScreenshotOptions{
+			Path:     playwright.String(filepath.Join(dir, "screenshot.png")),
+			FullPage: playwright.Bool(true),
+			Timeout:  playwright.Float(10000),
+		}); err != nil {
+			log.Printf("⚠️ Gagal mengambil screenshot: %v", err)
+		}
+
+		html, err := svc.Page.Content()
+		if err == nil {
+			os.WriteFile(filepath.Join(dir, "page.html"), []byte(html), 0o644)
+		}
+	}
+
+	if err := svc.saveTrace(filepath.Join(dir, "trace.zip")); err != nil {
+		log.Printf("⚠️ Gagal menyimpan trace: %v", err)
+	}
+
+	log.Printf("📸 Artefak kegagalan disimpan: %s", dir)
+	return &ArtifactError{Err: cause, Path: filepath.ToSlash(rel)}
+}
+
+// List mengembalikan semua file artefak milik satu supplier order (terbaru dulu)
+func (a *ArtifactStore) List(key string) ([]Artifact, error) {
+	if !validArtifactKey(key) {
+		return nil, ErrArtifactNotFound
+	}
+
+	root := filepath.Join(a.Dir, key)
+	var artifacts []Artifact
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		artifacts = append(artifacts, Artifact{
+			Path:      filepath.ToSlash(rel),
+			Size:      info.Size(),
+			CreatedAt: info.ModTime(),
+		})
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return []Artifact{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Path > artifacts[j].Path })
+	return artifacts, nil
+}
+
+// Open mengembalikan path file artefak di disk; path di luar folder order ditolak
+func (a *ArtifactStore) Open(key, path string) (string, error) {
+	if !validArtifactKey(key) {
+		return "", ErrArtifactNotFound
+	}
+
+	root := filepath.Join(a.Dir, key)
+	full := filepath.Join(root, filepath.FromSlash(path))
+	if !strings.HasPrefix(full, root+string(filepath.Separator)) {
+		return "", ErrArtifactNotFound
+	}
+
+	info, err := os.Stat(full)
+	if err != nil || info.IsDir() {
+		return "", ErrArtifactNotFound
+	}
+	return full, nil
+}
+
+func validArtifactKey(key string) bool {
+	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, `/\`)
+}
+
+// startTrace mulai merekam trace Playwright (screenshot + DOM snapshot).
+// Trace direkam per chunk; chunk di-reset setiap sesi dipakai order baru.
+func (s *MitraHiggsService) startTrace() {
+	tracing := s.Context.Tracing()
+	if err := tracing.Start(playwright.TracingStartOptions{
+		Screenshots: playwright.Bool(true),
+		Snapshots:   playwright.Bool(true),
+	}); err != nil {
+		log.Printf("⚠️ Gagal memulai trace: %v", err)
+		return
+	}
+	if err := tracing.StartChunk(); err != nil {
+		log.Printf("⚠️ Gagal memulai chunk trace: %v", err)
+		return
+	}
+	s.tracing = true
+}
+
+// ResetTrace membuang trace order sebelumnya dan mulai chunk baru
+func (s *MitraHiggsService) ResetTrace() {
+	if !s.tracing {
+		return
+	}
+	tracing := s.Context.Tracing()
+	tracing.StopChunk()
+	if err := tracing.StartChunk(); err != nil {
+		log.Printf("⚠️ Gagal reset trace: %v", err)
+		s.tracing = false
+	}
+}
+
+// saveTrace menyimpan chunk trace saat ini ke file lalu mulai chunk baru
+func (s *MitraHiggsService) saveTrace(path string) error {
+	if !s.tracing {
+		return fmt.Errorf("trace tidak aktif")
+	}
+	tracing := s.Context.Tracing()
+	err := tracing.StopChunk(path)
+	if startErr := tracing.StartChunk(); startErr != nil {
+		s.tracing = false
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package scraper
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -13,13 +14,28 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	// ErrLoginRejected: kredensial ditolak oleh web supplier (bukan timeout)
+	ErrLoginRejected = errors.New("login ditolak")
+
+	// ErrInvalidPlayer: ID tujuan (player) tidak dikenal oleh supplier
+	ErrInvalidPlayer = errors.New("ID player tidak valid")
+
+	// ErrPurchaseUncertain: tombol Kirim sudah diklik tapi hasilnya tidak diketahui
+	// (pembelian mungkin sudah terjadi di supplier, jangan diulang otomatis)
+	ErrPurchaseUncertain = errors.New("status pembelian tidak pasti")
+)
+
 type MitraHiggsService struct {
 	Pw       *playwright.Playwright
 	Browser  playwright.Browser
 	Context  playwright.BrowserContext
 	Page     playwright.Page
-	RedisKey string
+	RedisKey string // Prefix key cookie, disimpan per akun: <RedisKey>:<gameID>
 	Redis    *redis.Client
+	Config   *MitraHiggsConfig // Selector, URL & timeout (nil = default)
+
+	tracing bool // Playwright trace sedang direkam (lihat artifacts.go)
 }
 
 type SerializableCookie struct {
@@ -82,14 +98,45 @@ func NewMitraHiggsService(isDebug bool, redisClient *redis.Client) (*MitraHiggsS
 		return nil, err
 	}
 
-	return &MitraHiggsService{
+	svc := &MitraHiggsService{
 		Pw:       pw,
 		Browser:  browser,
 		Context:  ctx,
 		Page:     page,
 		RedisKey: "mitrahiggs:cookies",
 		Redis:    redisClient,
-	}, nil
+	}
+
+	// Trace direkam sejak awal agar kegagalan login pun bisa di-debug
+	svc.startTrace()
+
+	return svc, nil
+}
+
+// IsAlive memastikan browser & tab utama masih hidup
+func (s *MitraHiggsService) IsAlive() bool {
+	return s.Browser != nil && s.Browser.IsConnected() && s.Page != nil && !s.Page.IsClosed()
+}
+
+// IsLoggedIn membuka halaman trade. Jika sesi sudah habis, web supplier akan
+// mengarahkan kembali ke halaman login sehingga URL tidak lagi /trade/index.
+func (s *MitraHiggsService) IsLoggedIn() bool {
+	cfg := s.cfg()
+	_, err := s.Page.Goto(cfg.url(cfg.Paths.Trade), playwright.PageGotoOptions{
+		Timeout: ms(cfg.Timeouts.SessionCheck),
+	})
+	if err != nil {
+		return false
+	}
+	return strings.Contains(s.Page.URL(), cfg.Paths.Trade)
+}
+
+// cfg mengembalikan konfigurasi aktif (default jika belum diset)
+func (s *MitraHiggsService) cfg() *MitraHiggsConfig {
+	if s.Config == nil {
+		s.Config = DefaultMitraHiggsConfig()
+	}
+	return s.Config
 }
 
 func (s *MitraHiggsService) Close() {
@@ -101,14 +148,128 @@ func (s *MitraHiggsService) Close() {
 	}
 }
 
+func (s *MitraHiggsService) cookieKey(gameID string) string {
+	return fmt.Sprintf("%s:%s", s.RedisKey, gameID)
+}
+
+// restoreSession memuat cookie akun dari Redis ke BrowserContext lalu
+// memverifikasi sesi dengan membuka /trade/index. Return false jika cookie
+// tidak ada atau sudah ditolak web supplier (cookie lama dibersihkan).
+func (s *MitraHiggsService) restoreSession(ctx context.Context, gameID string) bool {
+	raw, err := s.Redis.Get(ctx, s.cookieKey(gameID)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Println("⚠️ Warning: Gagal baca cookie dari Redis:", err)
+		}
+		return false
+	}
+
+	var saved []SerializableCookie
+	if err := json.Unmarshal([]byte(raw), &saved); err != nil || len(saved) == 0 {
+		s.Redis.Del(ctx, s.cookieKey(gameID))
+		return false
+	}
+
+	cookies := make([]playwright.OptionalCookie, 0, len(saved))
+	for _, c := range saved {
+		cookie := playwright.OptionalCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   playwright.String(c.Domain),
+			Path:     playwright.String(c.Path),
+			Expires:  playwright.Float(c.Expires),
+			HttpOnly: playwright.Bool(c.HttpOnly),
+			Secure:   playwright.Bool(c.Secure),
+		}
+		if c.SameSite != "" {
+			sameSite := playwright.SameSiteAttribute(c.SameSite)
+			cookie.SameSite = &sameSite
+		}
+		cookies = append(cookies, cookie)
+	}
+
+	if err := s.Context.AddCookies(cookies); err != nil {
+		log.Println("⚠️ Warning: Gagal memuat cookie ke browser:", err)
+		return false
+	}
+
+	if !s.IsLoggedIn() {
+		log.Println("🍪 Cookie tersimpan ditolak (sesi expired), login ulang dengan password...")
+		s.Context.ClearCookies()
+		s.Redis.Del(ctx, s.cookieKey(gameID))
+		return false
+	}
+	return true
+}
+
+// SaveSession menyimpan cookie sesi yang sedang login ke Redis agar bisa dipakai
+// client HTTP. Dipanggil eksplisit karena sesi pool yang masih login tidak
+// melewati Login (dan tidak menyimpan cookie) saat di-Acquire.
+func (s *MitraHiggsService) SaveSession(ctx context.Context, gameID string) {
+	s.saveSession(ctx, gameID)
+}
+
+// saveSession menyimpan cookie browser ke Redis (per akun)
+func (s *MitraHiggsService) saveSession(ctx context.Context, gameID string) {
+	cookies, err := s.Context.Cookies()
+	if err != nil {
+		log.Println("⚠️ Warning: Gagal ambil cookie browser:", err)
+		return
+	}
+
+	saved := make([]SerializableCookie, 0, len(cookies))
+	for _, c := range cookies {
+		sameSite := ""
+		if c.SameSite != nil {
+			sameSite = string(*c.SameSite)
+		}
+		saved = append(saved, SerializableCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			HttpOnly: c.HttpOnly,
+			Secure:   c.Secure,
+			SameSite: sameSite,
+		})
+	}
+
+	cookieBytes, _ := json.Marshal(saved)
+	if err := s.Redis.Set(ctx, s.cookieKey(gameID), string(cookieBytes), 24*time.Hour).Err(); err != nil {
+		log.Println("⚠️ Warning: Gagal simpan cookie ke Redis:", err)
+	}
+}
+
+func (s *MitraHiggsService) hideInvitation() {
+	s.Page.Evaluate(`
+    try { 
+      hideInvitation(); 
+      document.getElementById('thickdivInvitation').style.display = 'none';
+    } catch(e) {}
+  `)
+}
+
 // === LOGIC LOGIN ===
+// Cookie dari Redis dicoba lebih dulu; login password hanya dilakukan jika
+// cookie tidak ada atau sudah ditolak.
 func (s *MitraHiggsService) Login(gameID, password string) error {
 	ctx := context.Background()
+
+	if s.restoreSession(ctx, gameID) {
+		log.Println("🍪 Login via cookie tersimpan berhasil.")
+		s.hideInvitation()
+		return nil
+	}
+
 	log.Println("🚀 Memulai proses Login (Optimized)...")
 
+	cfg := s.cfg()
+	sel := cfg.Selectors
+
 	// Timeout login dikurangi agar fail-fast jika macet
-	_, err := s.Page.Goto("https://mitrahiggs.com/", playwright.PageGotoOptions{
-		Timeout: playwright.Float(30000),
+	_, err := s.Page.Goto(cfg.url(cfg.Paths.Login), playwright.PageGotoOptions{
+		Timeout: ms(cfg.Timeouts.Navigation),
 	})
 	if err != nil {
 		return fmt.Errorf("gagal buka web: %v", err)
@@ -120,36 +281,36 @@ func (s *MitraHiggsService) Login(gameID, password string) error {
 
 	// 1. CEK & PINDAH KE ID LOGIN
 	isPasswordVisible := false
-	if vis, _ := s.Page.Locator("input[type='password']").IsVisible(); vis {
+	if vis, _ := s.Page.Locator(sel.PasswordInput).IsVisible(); vis {
 		isPasswordVisible = true
 	}
 
 	if !isPasswordVisible {
-		s.Page.Locator("span[name='index-html-id-login']").Click(playwright.LocatorClickOptions{Force: playwright.Bool(true)})
-		if vis, _ := s.Page.Locator("input[type='password']").IsVisible(); !vis {
-			s.Page.Locator(".login-text").Click(playwright.LocatorClickOptions{Force: playwright.Bool(true)})
+		s.Page.Locator(sel.IDLoginTab).Click(playwright.LocatorClickOptions{Force: playwright.Bool(true)})
+		if vis, _ := s.Page.Locator(sel.PasswordInput).IsVisible(); !vis {
+			s.Page.Locator(sel.IDLoginTabFallback).Click(playwright.LocatorClickOptions{Force: playwright.Bool(true)})
 		}
 	}
 
 	// 2. ISI FORM
-	s.Page.Locator("input[type='text']:visible").First().Fill(gameID)
-	s.Page.Locator("input[type='password']").Fill(password)
+	s.Page.Locator(sel.UsernameInput).First().Fill(gameID)
+	s.Page.Locator(sel.PasswordInput).Fill(password)
 
 	// 3. KLIK LOGIN
-	err = s.Page.Locator("#pwdLoginButton").Click(playwright.LocatorClickOptions{Force: playwright.Bool(true)})
+	err = s.Page.Locator(sel.LoginButton).Click(playwright.LocatorClickOptions{Force: playwright.Bool(true)})
 	if err != nil {
-		s.Page.Locator(".btnLogin").Click()
+		s.Page.Locator(sel.LoginButtonFallback).Click()
 	}
 
 	// 4. VERIFIKASI SUKSES
-	err = s.Page.WaitForURL("**/trade/index**", playwright.PageWaitForURLOptions{
-		Timeout: playwright.Float(15000),
+	err = s.Page.WaitForURL(cfg.tradeURLPattern(), playwright.PageWaitForURLOptions{
+		Timeout: ms(cfg.Timeouts.Login),
 	})
 
 	if err != nil {
-		if vis, _ := s.Page.Locator(".alert-danger").IsVisible(); vis {
-			msg, _ := s.Page.Locator(".alert-danger").TextContent()
-			return fmt.Errorf("login ditolak: %s", msg)
+		if vis, _ := s.Page.Locator(sel.LoginError).IsVisible(); vis {
+			msg, _ := s.Page.Locator(sel.LoginError).TextContent()
+			return fmt.Errorf("%w: %s", ErrLoginRejected, msg)
 		}
 		return fmt.Errorf("login timeout/gagal")
 	}
@@ -157,27 +318,118 @@ func (s *MitraHiggsService) Login(gameID, password string) error {
 	log.Println("✅ Login Sukses.")
 
 	// 5. TUTUP POPUP
-	s.Page.Evaluate(`
-    try { 
-      hideInvitation(); 
-      document.getElementById('thickdivInvitation').style.display = 'none';
-    } catch(e) {}
-  `)
+	s.hideInvitation()
 
 	// Simpan Cookie
-	cookies, _ := s.Context.Cookies()
-	cookieBytes, _ := json.Marshal(cookies)
+	s.saveSession(ctx, gameID)
+
+	return nil
+}
+
+// ValidateBuyer mengecek ID player lewat form top up tanpa membeli:
+// pilih produk, isi ID, klik Top Up lalu tunggu modal konfirmasi (berisi nama
+// player) atau alert error.
+func (s *MitraHiggsService) ValidateBuyer(playerID, productID string) (string, error) {
+	s.Page.Evaluate("try { hideInvitation(); Common.close(); } catch(e) {}")
+	defer s.Page.Evaluate("try { Common.close(); } catch(e) {}")
+
+	sel := s.cfg().Selectors
+	productSelector := fmt.Sprintf(sel.Product, productID)
+
+	if err := s.Page.Locator(productSelector).Click(); err != nil {
+		return "", fmt.Errorf("gagal klik produk: %v", err)
+	}
+	if err := s.Page.Locator(sel.PlayerIDInput).Fill(playerID); err != nil {
+		return "", fmt.Errorf("gagal mengisi ID: %v", err)
+	}
+	if err := s.Page.Locator(sel.TopupButton).Click(); err != nil {
+		return "", fmt.Errorf("gagal klik topup: %v", err)
+	}
+
+	for tryCount := 0; tryCount < 10; tryCount++ {
+		if vis, _ := s.Page.Locator(sel.BuyerName).IsVisible(); vis {
+			if name, _ := s.Page.Locator(sel.BuyerName).InnerText(); strings.TrimSpace(name) != "" {
+				return strings.TrimSpace(name), nil
+			}
+		}
+		if vis, _ := s.Page.Locator(sel.AlertBox).IsVisible(); vis {
+			txt, _ := s.Page.Locator(sel.AlertText).InnerText()
+			if txt != "" && txt != "null" && !strings.Contains(strings.ToLower(txt), "loading") {
+				return "", fmt.Errorf("GAGAL CEK USER: %s: %w", txt, ErrInvalidPlayer)
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return "", fmt.Errorf("modal konfirmasi tidak muncul saat cek user %s", playerID)
+}
 
-	err = s.Redis.Set(ctx, s.RedisKey, string(cookieBytes), 24*time.Hour).Err()
+// Status halaman pembayaran (hasil CheckPayment)
+const (
+	PaymentPending = "pending"
+	PaymentSuccess = "success"
+	PaymentExpired = "expired"
+)
+
+var (
+	paymentSuccessKeywords = []string{"pembayaran berhasil", "payment successful", "payment success", "transaksi berhasil", "sudah dibayar"}
+	paymentExpiredKeywords = []string{"kedaluwarsa", "kadaluarsa", "kadaluwarsa", "expired", "dibatalkan", "cancelled", "canceled"}
+)
+
+// CheckPayment membuka URL pembayaran di tab baru dan membaca statusnya dari
+// elemen status (selectors.payment_status), bukan seluruh teks halaman: halaman
+// yang masih menunggu pembayaran biasanya juga menulis "kedaluwarsa dalam ...".
+// Status sukses dicek lebih dulu. Tab utama (halaman trade) tidak disentuh.
+func (s *MitraHiggsService) CheckPayment(paymentURL string) (string, error) {
+	page, err := s.Context.NewPage()
 	if err != nil {
-		log.Println("⚠️ Warning: Gagal simpan cookie ke Redis:", err)
+		return "", fmt.Errorf("gagal buka tab pembayaran: %v", err)
 	}
+	defer page.Close()
 
-	return nil
+	cfg := s.cfg()
+	if _, err := page.Goto(paymentURL, playwright.PageGotoOptions{
+		Timeout: ms(cfg.Timeouts.PaymentPage),
+	}); err != nil {
+		return "", fmt.Errorf("gagal buka halaman pembayaran: %v", err)
+	}
+	page.WaitForLoadState(playwright.PageWaitForLoadStateOptions{State: playwright.LoadStateDomcontentloaded})
+
+	status := page.Locator(cfg.Selectors.PaymentStatus).First()
+	if n, _ := status.Count(); n == 0 {
+		// Elemen status tidak ada: anggap belum dibayar, jangan tebak dari teks lain
+		return PaymentPending, nil
+	}
+	text, err := status.InnerText()
+	if err != nil {
+		return "", fmt.Errorf("gagal membaca status pembayaran: %v", err)
+	}
+	return paymentState(text), nil
+}
+
+// paymentState menerjemahkan teks elemen status pembayaran. Sukses dicek lebih
+// dulu agar teks seperti "berhasil dibayar sebelum kedaluwarsa" tidak terbaca expired.
+func paymentState(text string) string {
+	text = strings.ToLower(text)
+	for _, kw := range paymentSuccessKeywords {
+		if strings.Contains(text, kw) {
+			return PaymentSuccess
+		}
+	}
+	for _, kw := range paymentExpiredKeywords {
+		if strings.Contains(text, kw) {
+			return PaymentExpired
+		}
+	}
+	return PaymentPending
 }
 
 // === PLACE ORDER (OPTIMIZED UNTUK TOKO KOIN + PAYMENT URL) ===
-func (s *MitraHiggsService) PlaceOrder(playerID, productID string, quantity int, paymentTypeID string) (string, error) {
+// Jika gagal di tengah loop, URL dari transaksi yang sudah sukses tetap
+// dikembalikan bersama error agar pemanggil tahu ada pembelian yang terjadi.
+// onUnit (opsional) dipanggil setiap satu unit sukses agar progres bisa dicatat.
+// Jika ctx dibatalkan (misal lease order hilang), unit berikutnya tidak dibeli.
+func (s *MitraHiggsService) PlaceOrder(ctx context.Context, playerID, productID string, quantity int, paymentTypeID string, onUnit func(paymentURL string)) (string, error) {
 	log.Printf("🛒 Memulai %d Transaksi untuk Player %s (Item ID: %s, Payment ID: %s)", quantity, playerID, productID, paymentTypeID)
 
 	s.Page.WaitForLoadState(playwright.PageWaitForLoadStateOptions{State: playwright.LoadStateDomcontentloaded})
@@ -187,14 +439,19 @@ func (s *MitraHiggsService) PlaceOrder(playerID, productID string, quantity int,
 
 	var successTrx []string
 
-	// Selector dinamis berdasarkan testing main.go
-	productSelector := fmt.Sprintf(`li[onclick*="ShopGoldcoinsInfull.chooseItem(%s"]`, productID)
-	paymentSelector := fmt.Sprintf(`li[onclick*="ShopGoldcoinsInfull.chooseInfull"][infullchannel="%s"]`, paymentTypeID)
-	idInputSelector := `#userId`
-	topupBtnSelector := `a[onclick="ShopGoldcoinsInfull.queryBuyer();"]`
-	kirimBtnSelector := `a[onclick="ShopGoldcoinsInfull.buyItem();"]`
+	// Selector dari konfigurasi supplier (lihat MitraHiggsConfig)
+	cfg := s.cfg()
+	sel := cfg.Selectors
+	productSelector := fmt.Sprintf(sel.Product, productID)
+	paymentSelector := fmt.Sprintf(sel.PaymentMethod, paymentTypeID)
+	idInputSelector := sel.PlayerIDInput
+	topupBtnSelector := sel.TopupButton
+	kirimBtnSelector := sel.ConfirmButton
 
 	for i := 1; i <= quantity; i++ {
+		if err := ctx.Err(); err != nil {
+			return strings.Join(successTrx, ","), fmt.Errorf("dibatalkan sebelum loop %d: %w", i, err)
+		}
 		if i == 1 || i%5 == 0 {
 			log.Printf("🔄 Loop %d/%d...", i, quantity)
 		}
@@ -205,31 +462,31 @@ func (s *MitraHiggsService) PlaceOrder(playerID, productID string, quantity int,
 
 		// B. PILIH PRODUK
 		if err := s.Page.Locator(productSelector).Click(); err != nil {
-			return "", fmt.Errorf("gagal klik produk di loop %d: %v", i, err)
+			return strings.Join(successTrx, ","), fmt.Errorf("gagal klik produk di loop %d: %v", i, err)
 		}
 
 		// C. INPUT ID PLAYER
 		if err := s.Page.Locator(idInputSelector).Fill(playerID); err != nil {
-			return "", fmt.Errorf("gagal mengisi ID di loop %d: %v", i, err)
+			return strings.Join(successTrx, ","), fmt.Errorf("gagal mengisi ID di loop %d: %v", i, err)
 		}
 
 		// D. PILIH METODE PEMBAYARAN
 		if err := s.Page.Locator(paymentSelector).Click(); err != nil {
-			return "", fmt.Errorf("gagal memilih metode pembayaran di loop %d: %v", i, err)
+			return strings.Join(successTrx, ","), fmt.Errorf("gagal memilih metode pembayaran di loop %d: %v", i, err)
 		}
 
 		// E. KLIK TOP UP
 		if err := s.Page.Locator(topupBtnSelector).Click(); err != nil {
-			return "", fmt.Errorf("gagal klik topup di loop %d: %v", i, err)
+			return strings.Join(successTrx, ","), fmt.Errorf("gagal klik topup di loop %d: %v", i, err)
 		}
 
 		// Fail-Fast: Cek jika ID tidak valid maka web akan memunculkan alert `#publicTip`
 		time.Sleep(500 * time.Millisecond)
-		if vis, _ := s.Page.Locator("#publicTip").IsVisible(); vis {
-			txt, _ := s.Page.Locator("#publicTxt").InnerText()
+		if vis, _ := s.Page.Locator(sel.AlertBox).IsVisible(); vis {
+			txt, _ := s.Page.Locator(sel.AlertText).InnerText()
 			if txt != "" && txt != "null" && !strings.Contains(strings.ToLower(txt), "loading") {
 				s.Page.Evaluate("Common.close()")
-				return "", fmt.Errorf("GAGAL CEK USER (Loop %d): %s", i, txt)
+				return strings.Join(successTrx, ","), fmt.Errorf("GAGAL CEK USER (Loop %d): %s: %w", i, txt, ErrInvalidPlayer)
 			}
 		}
 
@@ -239,12 +496,13 @@ func (s *MitraHiggsService) PlaceOrder(playerID, productID string, quantity int,
 			return s.Page.Locator(kirimBtnSelector).Click()
 		})
 		if err != nil {
-			return "", fmt.Errorf("gagal menangkap tab pembayaran baru di loop %d: %v", i, err)
+			return strings.Join(successTrx, ","), fmt.Errorf("gagal menangkap tab pembayaran baru di loop %d: %v: %w", i, err, ErrPurchaseUncertain)
 		}
 
 		// G. TUNGGU REDIRECT DARI ABOUT:BLANK KE HALAMAN PAYMENT
 		log.Printf("⏳ Menunggu redirect dari server payment (Loop %d)...", i)
-		for j := 0; j < 20; j++ { // Maksimal tunggu 10 detik (20 * 500ms)
+		deadline := time.Now().Add(cfg.Timeouts.redirect())
+		for time.Now().Before(deadline) { // Maksimal tunggu timeouts.payment_redirect
 			if newPage.URL() != "about:blank" {
 				break
 			}
@@ -262,6 +520,9 @@ func (s *MitraHiggsService) PlaceOrder(playerID, productID string, quantity int,
 
 		// Simpan transaksi
 		successTrx = append(successTrx, finalURL)
+		if onUnit != nil {
+			onUnit(finalURL)
+		}
 
 		// H. TUTUP TAB BARU (Sangat Penting: Menghindari Memory Leak)
 		newPage.Close()
@@ -0,0 +1,221 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultMitraHiggsBaseURL dipakai jika Supplier.base_url kosong
+const DefaultMitraHiggsBaseURL = "https://mitrahiggs.com"
+
+var ErrInvalidConfig = errors.New("konfigurasi scraper tidak valid")
+
+// MitraHiggsConfig adalah selector, URL, dan timeout yang dipakai scraper.
+// Disimpan per supplier (Supplier.scraper_config, JSON) sehingga perubahan di
+// web supplier cukup diubah lewat API admin tanpa redeploy. Field yang tidak
+// diisi memakai nilai default.
+type MitraHiggsConfig struct {
+	Version   int                 `json:"version"` // Diisi dari Supplier.scraper_config_version
+	BaseURL   string              `json:"-"`       // Diisi dari Supplier.base_url
+	Paths     MitraHiggsPaths     `json:"paths"`
+	Selectors MitraHiggsSelectors `json:"selectors"`
+	Timeouts  MitraHiggsTimeouts  `json:"timeouts"`
+}
+
+type MitraHiggsPaths struct {
+	Login string `json:"login"` // Halaman form login
+	Trade string `json:"trade"` // Halaman toko; URL ini juga penanda sesi masih login
+}
+
+type MitraHiggsSelectors struct {
+	PasswordInput       string `json:"password_input"`
+	IDLoginTab          string `json:"id_login_tab"`
+	IDLoginTabFallback  string `json:"id_login_tab_fallback"`
+	UsernameInput       string `json:"username_input"`
+	LoginButton         string `json:"login_button"`
+	LoginButtonFallback string `json:"login_button_fallback"`
+	LoginError          string `json:"login_error"`
+
+	Product       string `json:"product"`        // %s = ID produk supplier
+	PaymentMethod string `json:"payment_method"` // %s = kode payment type
+	PlayerIDInput string `json:"player_id_input"`
+	TopupButton   string `json:"topup_button"`
+	ConfirmButton string `json:"confirm_button"` // Tombol "Kirim" yang membuka tab pembayaran
+	BuyerName     string `json:"buyer_name"`     // Nama player di modal konfirmasi (ValidateBuyer)
+	AlertBox      string `json:"alert_box"`
+	AlertText     string `json:"alert_text"`
+
+	PaymentStatus string `json:"payment_status"` // Elemen status di halaman pembayaran (CheckPayment)
+}
+
+// MitraHiggsTimeouts dalam milidetik
+type MitraHiggsTimeouts struct {
+	Navigation      int `json:"navigation"`       // Buka halaman login
+	Login           int `json:"login"`            // Tunggu redirect ke halaman trade setelah login
+	SessionCheck    int `json:"session_check"`    // Health check sesi (buka halaman trade)
+	PaymentPage     int `json:"payment_page"`     // Buka halaman pembayaran (CheckPayment)
+	PaymentRedirect int `json:"payment_redirect"` // Tunggu tab pembayaran keluar dari about:blank
+	ItemDelay       int `json:"item_delay"`       // Jeda antar bahan baku dalam satu order
+}
+
+func DefaultMitraHiggsConfig() *MitraHiggsConfig {
+	return &MitraHiggsConfig{
+		BaseURL: DefaultMitraHiggsBaseURL,
+		Paths: MitraHiggsPaths{
+			Login: "/",
+			Trade: "/trade/index",
+		},
+		Selectors: MitraHiggsSelectors{
+			PasswordInput:       "input[type='password']",
+			IDLoginTab:          "span[name='index-html-id-login']",
+			IDLoginTabFallback:  ".login-text",
+			UsernameInput:       "input[type='text']:visible",
+			LoginButton:         "#pwdLoginButton",
+			LoginButtonFallback: ".btnLogin",
+			LoginError:          ".alert-danger",
+
+			Product:       `li[onclick*="ShopGoldcoinsInfull.chooseItem(%s"]`,
+			PaymentMethod: `li[onclick*="ShopGoldcoinsInfull.chooseInfull"][infullchannel="%s"]`,
+			PlayerIDInput: "#userId",
+			TopupButton:   `a[onclick="ShopGoldcoinsInfull.queryBuyer();"]`,
+			ConfirmButton: `a[onclick="ShopGoldcoinsInfull.buyItem();"]`,
+			BuyerName:     "#queryBuyerName",
+			AlertBox:      "#publicTip",
+			AlertText:     "#publicTxt",
+
+			PaymentStatus: ".status",
+		},
+		Timeouts: MitraHiggsTimeouts{
+			Navigation:      30000,
+			Login:           15000,
+			SessionCheck:    15000,
+			PaymentPage:     20000,
+			PaymentRedirect: 10000,
+			ItemDelay:       2000,
+		},
+	}
+}
+
+// ParseMitraHiggsConfig membaca JSON konfigurasi di atas nilai default lalu memvalidasinya.
+// raw kosong = konfigurasi default.
+func ParseMitraHiggsConfig(raw, baseURL string) (*MitraHiggsConfig, error) {
+	return parseMitraHiggsConfig(raw, baseURL, false)
+}
+
+// ParseMitraHiggsConfigStrict sama dengan ParseMitraHiggsConfig, tapi menolak field
+// yang tidak dikenal (misal salah ketik "selector.product"). Dipakai saat admin
+// mengubah konfigurasi; konfigurasi tersimpan tetap dibaca longgar.
+func ParseMitraHiggsConfigStrict(raw, baseURL string) (*MitraHiggsConfig, error) {
+	return parseMitraHiggsConfig(raw, baseURL, true)
+}
+
+func parseMitraHiggsConfig(raw, baseURL string, strict bool) (*MitraHiggsConfig, error) {
+	cfg := DefaultMitraHiggsConfig()
+	if strings.TrimSpace(raw) != "" {
+		decoder := json.NewDecoder(strings.NewReader(raw))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+	if baseURL != "" {
+		cfg.BaseURL = strings.TrimRight(baseURL, "/")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate memastikan semua selector terisi, selector produk & payment punya
+// tepat satu %s, path diawali "/", dan timeout masuk akal (1 detik - 5 menit)
+func (c *MitraHiggsConfig) Validate() error {
+	var problems []string
+
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, "base_url harus URL http(s) yang valid")
+	}
+
+	paths := map[string]string{"paths.login": c.Paths.Login, "paths.trade": c.Paths.Trade}
+	for name, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			problems = append(problems, name+" harus diawali '/'")
+		}
+	}
+
+	s := c.Selectors
+	selectors := map[string]string{
+		"selectors.password_input":        s.PasswordInput,
+		"selectors.id_login_tab":          s.IDLoginTab,
+		"selectors.id_login_tab_fallback": s.IDLoginTabFallback,
+		"selectors.username_input":        s.UsernameInput,
+		"selectors.login_button":          s.LoginButton,
+		"selectors.login_button_fallback": s.LoginButtonFallback,
+		"selectors.login_error":           s.LoginError,
+		"selectors.product":               s.Product,
+		"selectors.payment_method":        s.PaymentMethod,
+		"selectors.player_id_input":       s.PlayerIDInput,
+		"selectors.topup_button":          s.TopupButton,
+		"selectors.confirm_button":        s.ConfirmButton,
+		"selectors.buyer_name":            s.BuyerName,
+		"selectors.alert_box":             s.AlertBox,
+		"selectors.alert_text":            s.AlertText,
+		"selectors.payment_status":        s.PaymentStatus,
+	}
+	for name, sel := range selectors {
+		if strings.TrimSpace(sel) == "" {
+			problems = append(problems, name+" tidak boleh kosong")
+		}
+	}
+	for name, sel := range map[string]string{"selectors.product": s.Product, "selectors.payment_method": s.PaymentMethod} {
+		if strings.Count(sel, "%s") != 1 || strings.Count(sel, "%") != 1 {
+			problems = append(problems, name+" harus mengandung tepat satu %s")
+		}
+	}
+
+	t := c.Timeouts
+	timeouts := map[string]int{
+		"timeouts.navigation":       t.Navigation,
+		"timeouts.login":            t.Login,
+		"timeouts.session_check":    t.SessionCheck,
+		"timeouts.payment_page":     t.PaymentPage,
+		"timeouts.payment_redirect": t.PaymentRedirect,
+		"timeouts.item_delay":       t.ItemDelay,
+	}
+	for name, v := range timeouts {
+		if v < 1000 || v > 300000 {
+			problems = append(problems, name+" harus antara 1000 dan 300000 ms")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c *MitraHiggsConfig) url(path string) string {
+	return c.BaseURL + path
+}
+
+// tradeURLPattern adalah glob URL halaman trade untuk WaitForURL
+func (c *MitraHiggsConfig) tradeURLPattern() string {
+	return "**" + c.Paths.Trade + "**"
+}
+
+func ms(n int) *float64 {
+	f := float64(n)
+	return &f
+}
+
+func (t MitraHiggsTimeouts) redirect() time.Duration {
+	return time.Duration(t.PaymentRedirect) * time.Millisecond
+}
@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMitraHiggsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *MitraHiggsConfig)
+		problem string // Potongan pesan error yang diharapkan; kosong = valid
+	}{
+		{"default", func(c *MitraHiggsConfig) {}, ""},
+		{"base_url bukan http(s)", func(c *MitraHiggsConfig) { c.BaseURL = "ftp://mitrahiggs.com" }, "base_url"},
+		{"base_url tanpa host", func(c *MitraHiggsConfig) { c.BaseURL = "https://" }, "base_url"},
+		{"path tanpa '/'", func(c *MitraHiggsConfig) { c.Paths.Trade = "trade/index" }, "paths.trade"},
+		{"selector kosong", func(c *MitraHiggsConfig) { c.Selectors.LoginButton = " " }, "selectors.login_button"},
+		{"selector nama player kosong", func(c *MitraHiggsConfig) { c.Selectors.BuyerName = "" }, "selectors.buyer_name"},
+		{"selector status pembayaran kosong", func(c *MitraHiggsConfig) { c.Selectors.PaymentStatus = "" }, "selectors.payment_status"},
+		{"selector produk tanpa %s", func(c *MitraHiggsConfig) { c.Selectors.Product = "li.item" }, "selectors.product"},
+		{"selector payment dua %s", func(c *MitraHiggsConfig) { c.Selectors.PaymentMethod = "li[a=%s][b=%s]" }, "selectors.payment_method"},
+		{"selector produk %d", func(c *MitraHiggsConfig) { c.Selectors.Product = "li[a=%s][b=%d]" }, "selectors.product"},
+		{"timeout terlalu kecil", func(c *MitraHiggsConfig) { c.Timeouts.Login = 999 }, "timeouts.login"},
+		{"jeda antar item kosong", func(c *MitraHiggsConfig) { c.Timeouts.ItemDelay = 0 }, "timeouts.item_delay"},
+		{"timeout terlalu besar", func(c *MitraHiggsConfig) { c.Timeouts.PaymentPage = 300001 }, "timeouts.payment_page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultMitraHiggsConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.problem == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, harus valid", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("Validate() = %v, harus ErrInvalidConfig tentang %s", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestParseMitraHiggsConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		strict bool
+		ok     bool
+	}{
+		{"kosong = default", "", false, true},
+		{"override sebagian", `{"timeouts": {"login": 5000}}`, true, true},
+		{"field tidak dikenal, longgar", `{"selector": {"product": "li"}}`, false, true},
+		{"field tidak dikenal, strict", `{"selector": {"product": "li"}}`, true, false},
+		{"field bersarang tidak dikenal, strict", `{"timeouts": {"logn": 5000}}`, true, false},
+		{"JSON rusak", `{"timeouts":`, false, false},
+		{"hasil override tidak valid", `{"paths": {"login": "login"}}`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse := ParseMitraHiggsConfig
+			if tt.strict {
+				parse = ParseMitraHiggsConfigStrict
+			}
+
+			cfg, err := parse(tt.raw, "https://mitra.example.com/")
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidConfig) {
+					t.Fatalf("err = %v, harus ErrInvalidConfig", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v, harus valid", err)
+			}
+			if cfg.BaseURL != "https://mitra.example.com" {
+				t.Fatalf("BaseURL = %q, slash akhir harus dibuang", cfg.BaseURL)
+			}
+		})
+	}
+}
+
+func TestPaymentState(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Pembayaran berhasil, koin sudah dikirim", PaymentSuccess},
+		{"PAYMENT SUCCESSFUL", PaymentSuccess},
+		{"Pembayaran berhasil sebelum kedaluwarsa", PaymentSuccess},
+		{"Transaksi kedaluwarsa", PaymentExpired},
+		{"Order cancelled", PaymentExpired},
+		{"Menunggu pembayaran, silakan scan QR di bawah", PaymentPending},
+		{"", PaymentPending},
+	}
+
+	for _, tt := range tests {
+		if got := paymentState(tt.text); got != tt.want {
+			t.Errorf("paymentState(%q) = %s, harus %s", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSessionExpired: cookie tidak ada / ditolak server, perlu login ulang lewat browser
+var ErrSessionExpired = errors.New("sesi supplier expired")
+
+// APIError adalah respon endpoint trade/* dengan code != 0
+type APIError struct {
+	Endpoint string
+	Code     int
+	Message  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s gagal (code %d): %s", e.Endpoint, e.Code, e.Message)
+}
+
+// Unwrap memetakan code API ke error umum agar bisa dicek dengan errors.Is
+func (e *APIError) Unwrap() error {
+	switch e.Code {
+	case 3001: // user tidak ada
+		return ErrInvalidPlayer
+	}
+	return nil
+}
+
+// Buyer adalah hasil trade/queryBuyer
+type Buyer struct {
+	NickName string `json:"nickName"`
+	UserID   int64  `json:"userId"`
+}
+
+type apiResponse struct {
+	Code   int             `json:"code"`
+	ErrMsg string          `json:"errMsg"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// MitraHiggsHTTPClient memanggil endpoint trade/* secara langsung (tanpa klik DOM)
+// memakai cookie sesi hasil login Playwright yang tersimpan di Redis.
+type MitraHiggsHTTPClient struct {
+	BaseURL   string
+	TradePath string // Halaman toko, dipakai untuk cek sesi (redirect = sesi habis)
+	RedisKey  string
+	Redis     *redis.Client
+	HTTP      *http.Client
+
+	cookies []*http.Cookie
+}
+
+func NewMitraHiggsHTTPClient(baseURL string, redisClient *redis.Client) *MitraHiggsHTTPClient {
+	if baseURL == "" {
+		baseURL = DefaultMitraHiggsBaseURL
+	}
+	return &MitraHiggsHTTPClient{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		TradePath: DefaultMitraHiggsConfig().Paths.Trade,
+		RedisKey:  "mitrahiggs:cookies",
+		Redis:     redisClient,
+		HTTP: &http.Client{
+			Timeout: 20 * time.Second,
+			// Redirect (ke halaman login) berarti sesi sudah habis
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// LoadCookies memuat cookie akun dari Redis (disimpan oleh MitraHiggsService.Login)
+func (c *MitraHiggsHTTPClient) LoadCookies(ctx context.Context, gameID string) error {
+	raw, err := c.Redis.Get(ctx, fmt.Sprintf("%s:%s", c.RedisKey, gameID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrSessionExpired
+		}
+		return err
+	}
+
+	var saved []SerializableCookie
+	if err := json.Unmarshal([]byte(raw), &saved); err != nil || len(saved) == 0 {
+		return ErrSessionExpired
+	}
+
+	c.cookies = c.cookies[:0]
+	for _, sc := range saved {
+		c.cookies = append(c.cookies, &http.Cookie{Name: sc.Name, Value: sc.Value})
+	}
+	return nil
+}
+
+// CheckSession memastikan cookie masih diterima dengan membuka halaman toko
+// (sama seperti MitraHiggsService.IsLoggedIn). Redirect / 401 = ErrSessionExpired.
+func (c *MitraHiggsHTTPClient) CheckSession(ctx context.Context) error {
+	if len(c.cookies) == 0 {
+		return ErrSessionExpired
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+c.TradePath, nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("cek sesi: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 || resp.StatusCode == http.StatusUnauthorized {
+		return ErrSessionExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cek sesi: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// QueryBuyer mengecek ID player. Code 3001 = user tidak ada (ErrInvalidPlayer).
+func (c *MitraHiggsHTTPClient) QueryBuyer(ctx context.Context, buyerID string) (*Buyer, error) {
+	data, err := c.post(ctx, "trade/queryBuyer", url.Values{"buyerId": {buyerID}}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var buyer Buyer
+	if err := json.Unmarshal(data, &buyer); err != nil {
+		return nil, fmt.Errorf("respon queryBuyer tidak valid: %v", err)
+	}
+	return &buyer, nil
+}
+
+// SellCard mengirim satu item ke player. Kegagalan setelah request terkirim
+// (timeout, koneksi putus, respon rusak) dikembalikan sebagai ErrPurchaseUncertain
+// karena item mungkin sudah terjual; retry bisa membuat penjualan dobel.
+func (c *MitraHiggsHTTPClient) SellCard(ctx context.Context, itemID, buyerID string) error {
+	_, err := c.post(ctx, "trade/sellCard", url.Values{"itemId": {itemID}, "buyerId": {buyerID}}, true)
+	return err
+}
+
+func (c *MitraHiggsHTTPClient) setHeaders(req *http.Request) {
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Linux; Android 10; SM-G960F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Mobile Safari/537.36")
+	for _, ck := range c.cookies {
+		req.AddCookie(ck)
+	}
+}
+
+// post memanggil endpoint trade/*. sideEffect = endpoint mengubah data di supplier
+// (misal sellCard): error setelah header request terkirim tidak bisa dipastikan
+// gagal, sehingga dibungkus ErrPurchaseUncertain. Error sebelum terkirim (DNS,
+// koneksi ditolak) tetap error biasa yang aman di-retry.
+func (c *MitraHiggsHTTPClient) post(ctx context.Context, endpoint string, form url.Values, sideEffect bool) (json.RawMessage, error) {
+	if len(c.cookies) == 0 {
+		return nil, ErrSessionExpired
+	}
+
+	var sent atomic.Bool
+	trace := &httptrace.ClientTrace{
+		WroteHeaders: func() { sent.Store(true) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodPost, c.BaseURL+"/"+endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+	c.setHeaders(req)
+
+	uncertain := func(err error) error {
+		if sideEffect && sent.Load() {
+			return fmt.Errorf("%w: %s: %v", ErrPurchaseUncertain, endpoint, err)
+		}
+		return fmt.Errorf("%s: %v", endpoint, err)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, uncertain(err)
+	}
+	defer resp.Body.Close()
+
+	// Redirect ke halaman login / 401: request ditolak sebelum diproses
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 || resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrSessionExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, uncertain(fmt.Errorf("HTTP %d", resp.StatusCode))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, uncertain(err)
+	}
+
+	var result apiResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		// Server mengembalikan HTML (halaman login) jika sesi sudah habis
+		if strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+			return nil, ErrSessionExpired
+		}
+		return nil, uncertain(fmt.Errorf("respon tidak valid: %v", err))
+	}
+	if result.Code != 0 {
+		return nil, &APIError{Endpoint: endpoint, Code: result.Code, Message: result.ErrMsg}
+	}
+	return result.Data, nil
+}
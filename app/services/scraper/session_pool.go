@@ -0,0 +1,281 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionPool menyimpan MitraHiggsService yang sudah login agar bisa dipakai
+// ulang antar order, sehingga order tidak perlu start browser + login lagi.
+// Sesi di-recycle setelah MaxOrders order, setelah idle terlalu lama, atau
+// ketika order yang memakainya gagal.
+type SessionPool struct {
+	Redis     *redis.Client
+	Debug     bool
+	Artifacts *ArtifactStore // Artefak kegagalan (screenshot, HTML, trace)
+	MaxOrders int            // Recycle browser setelah dipakai N order
+	MaxIdle   time.Duration  // Tutup sesi yang tidak dipakai selama ini
+
+	mu     sync.Mutex
+	idle   map[string][]*pooledSession // Sesi siap pakai per akun supplier
+	inUse  map[*MitraHiggsService]*pooledSession
+	closed bool
+
+	// launch membuat sesi baru yang sudah login, revive menyiapkan sesi idle
+	// (health check + login ulang). Diganti di tes agar pool bisa diuji tanpa browser.
+	launch func(key string, cfg *MitraHiggsConfig, username, password string) (*MitraHiggsService, error)
+	revive func(svc *MitraHiggsService, key string, cfg *MitraHiggsConfig, username, password string) error
+}
+
+type pooledSession struct {
+	svc      *MitraHiggsService
+	account  string
+	key      string // Supplier order yang sedang memakai sesi (folder artefak)
+	orders   int
+	lastUsed time.Time
+}
+
+var ErrSessionPoolClosed = errors.New("session pool sudah ditutup")
+
+// errSessionDead: browser sesi idle sudah mati, sesi dibuang dan dicari yang lain
+var errSessionDead = errors.New("browser sesi sudah mati")
+
+func NewSessionPool(redisClient *redis.Client) *SessionPool {
+	maxOrders := 50
+	if n, err := strconv.Atoi(os.Getenv("MH_SESSION_MAX_ORDERS")); err == nil && n > 0 {
+		maxOrders = n
+	}
+	maxIdle := 15 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("MH_SESSION_MAX_IDLE")); err == nil && d > 0 {
+		maxIdle = d
+	}
+
+	p := &SessionPool{
+		Redis:     redisClient,
+		Artifacts: NewArtifactStore(),
+		MaxOrders: maxOrders,
+		MaxIdle:   maxIdle,
+		idle:      make(map[string][]*pooledSession),
+		inUse:     make(map[*MitraHiggsService]*pooledSession),
+	}
+	p.launch = p.launchSession
+	p.revive = p.reviveSession
+	return p
+}
+
+// Acquire mengambil sesi yang sudah login untuk akun tersebut.
+// Sesi idle di-health-check dulu (browser hidup & masih login); jika sesi
+// sudah expired dilakukan login ulang, jika browser mati dibuat yang baru.
+// key (ID supplier order, boleh kosong) dipakai sebagai folder artefak jika gagal.
+// cfg (nil = default) dipasang ulang setiap Acquire agar perubahan konfigurasi
+// scraper langsung berlaku untuk sesi yang sudah ada.
+func (p *SessionPool) Acquire(key string, cfg *MitraHiggsConfig, username, password string) (*MitraHiggsService, error) {
+	for {
+		// Sesi idle langsung ditandai dipakai (di bawah lock yang sama) sehingga
+		// tidak bisa diambil Acquire lain dan tetap ditutup oleh Close
+		ps, err := p.takeIdle(username, key)
+		if err != nil {
+			return nil, err
+		}
+		if ps == nil {
+			break
+		}
+
+		if err := p.revive(ps.svc, key, cfg, username, password); err != nil {
+			p.discard(ps.svc)
+			if errors.Is(err, errSessionDead) {
+				log.Printf("♻️ Sesi %s mati, dibuang", username)
+				continue
+			}
+			return nil, err
+		}
+
+		log.Printf("⚡ Memakai sesi MitraHiggs yang sudah login (%s, order ke-%d)", username, ps.orders+1)
+		return ps.svc, nil
+	}
+
+	// Tidak ada sesi idle: buat browser baru & login
+	svc, err := p.launch(key, cfg, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &pooledSession{svc: svc, account: username, key: key}
+	if !p.markInUse(ps) {
+		svc.Close()
+		return nil, ErrSessionPoolClosed
+	}
+	return svc, nil
+}
+
+// reviveSession menyiapkan sesi idle: browser harus hidup, konfigurasi dipasang
+// ulang, dan login ulang jika sesi di supplier sudah expired
+func (p *SessionPool) reviveSession(svc *MitraHiggsService, key string, cfg *MitraHiggsConfig, username, password string) error {
+	if !svc.IsAlive() {
+		return errSessionDead
+	}
+
+	svc.ResetTrace()
+	svc.Config = cfg
+
+	if !svc.IsLoggedIn() {
+		log.Printf("🔑 Sesi %s expired, login ulang...", username)
+		if err := svc.Login(username, password); err != nil {
+			return p.Artifacts.Capture(svc, key, err)
+		}
+	}
+	return nil
+}
+
+// launchSession membuka browser baru lalu login
+func (p *SessionPool) launchSession(key string, cfg *MitraHiggsConfig, username, password string) (*MitraHiggsService, error) {
+	svc, err := NewMitraHiggsService(p.Debug, p.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("Browser Init Failed: %w", err)
+	}
+
+	svc.Config = cfg
+
+	log.Println("🔑 Logging in...")
+	if err := svc.Login(username, password); err != nil {
+		err = p.Artifacts.Capture(svc, key, err)
+		svc.Close()
+		return nil, err
+	}
+	return svc, nil
+}
+
+// CaptureFailure menyimpan artefak kegagalan sesi yang sedang dipakai ke folder
+// supplier order-nya. Error dikembalikan terbungkus ArtifactError.
+func (p *SessionPool) CaptureFailure(svc *MitraHiggsService, err error) error {
+	p.mu.Lock()
+	ps, ok := p.inUse[svc]
+	p.mu.Unlock()
+
+	if !ok {
+		return err
+	}
+	return p.Artifacts.Capture(svc, ps.key, err)
+}
+
+// Release mengembalikan sesi ke pool. Sesi ditutup (recycle) jika order gagal,
+// sudah mencapai MaxOrders, atau pool sedang ditutup.
+func (p *SessionPool) Release(svc *MitraHiggsService, orderErr error) {
+	p.mu.Lock()
+	ps, ok := p.inUse[svc]
+	delete(p.inUse, svc)
+
+	if !ok {
+		p.mu.Unlock()
+		svc.Close()
+		return
+	}
+
+	ps.orders++
+	ps.lastUsed = time.Now()
+
+	// ID player salah bukan masalah browser, sesi tetap layak dipakai
+	recycle := p.closed || ps.orders >= p.MaxOrders ||
+		(orderErr != nil && !errors.Is(orderErr, ErrInvalidPlayer))
+
+	if !recycle {
+		p.idle[ps.account] = append(p.idle[ps.account], ps)
+	}
+	p.mu.Unlock()
+
+	if recycle {
+		log.Printf("♻️ Recycle sesi MitraHiggs %s (order: %d, error: %v)", ps.account, ps.orders, orderErr)
+		svc.Close()
+	}
+}
+
+// Close menutup semua sesi (idle maupun yang sedang dipakai).
+// Dipanggil saat shutdown; Acquire setelahnya akan gagal.
+func (p *SessionPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+
+	var sessions []*MitraHiggsService
+	for account, list := range p.idle {
+		for _, ps := range list {
+			sessions = append(sessions, ps.svc)
+		}
+		delete(p.idle, account)
+	}
+	for svc := range p.inUse {
+		sessions = append(sessions, svc)
+		delete(p.inUse, svc)
+	}
+	p.mu.Unlock()
+
+	for _, svc := range sessions {
+		svc.Close()
+	}
+	if len(sessions) > 0 {
+		log.Printf("🧹 %d sesi browser MitraHiggs ditutup", len(sessions))
+	}
+}
+
+// takeIdle mengambil sesi idle terakhir (LIFO) dan langsung menandainya dipakai
+// oleh key. Sesi yang terlalu lama idle ditutup.
+func (p *SessionPool) takeIdle(account, key string) (*pooledSession, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrSessionPoolClosed
+	}
+
+	var stale []*pooledSession
+	var picked *pooledSession
+
+	list := p.idle[account]
+	for len(list) > 0 {
+		ps := list[len(list)-1]
+		list = list[:len(list)-1]
+
+		if time.Since(ps.lastUsed) > p.MaxIdle {
+			stale = append(stale, ps)
+			continue
+		}
+		picked = ps
+		break
+	}
+	p.idle[account] = list
+	if picked != nil {
+		picked.key = key
+		p.inUse[picked.svc] = picked
+	}
+	p.mu.Unlock()
+
+	for _, ps := range stale {
+		log.Printf("♻️ Sesi %s idle terlalu lama, ditutup", ps.account)
+		ps.svc.Close()
+	}
+	return picked, nil
+}
+
+// discard melepas sesi dari daftar dipakai lalu menutupnya (sesi tidak kembali ke pool)
+func (p *SessionPool) discard(svc *MitraHiggsService) {
+	p.mu.Lock()
+	delete(p.inUse, svc)
+	p.mu.Unlock()
+	svc.Close()
+}
+
+func (p *SessionPool) markInUse(ps *pooledSession) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+	p.inUse[ps.svc] = ps
+	return true
+}
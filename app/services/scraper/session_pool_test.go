@@ -0,0 +1,157 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSessionPool membuat pool tanpa browser: launch membuat sesi kosong dan
+// revive menunggu sebentar (seperti health check) sebelum sesi siap dipakai
+func newTestSessionPool(t *testing.T) (*SessionPool, *sync.Mutex, *int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	launched := 0
+
+	p := NewSessionPool(nil)
+	p.launch = func(key string, cfg *MitraHiggsConfig, username, password string) (*MitraHiggsService, error) {
+		mu.Lock()
+		launched++
+		mu.Unlock()
+		return &MitraHiggsService{}, nil
+	}
+	p.revive = func(svc *MitraHiggsService, key string, cfg *MitraHiggsConfig, username, password string) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	return p, &mu, &launched
+}
+
+func TestSessionPoolConcurrentAcquire(t *testing.T) {
+	p, mu, launched := newTestSessionPool(t)
+
+	// Satu sesi idle untuk akun ini
+	idle, err := p.Acquire("order-0", nil, "akun", "rahasia")
+	if err != nil {
+		t.Fatalf("Acquire awal: %v", err)
+	}
+	p.Release(idle, nil)
+
+	var wg sync.WaitGroup
+	got := make([]*MitraHiggsService, 2)
+	errs := make([]error, 2)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], errs[i] = p.Acquire(fmt.Sprintf("order-%d", i+1), nil, "akun", "rahasia")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Acquire #%d: %v", i, err)
+		}
+	}
+	if got[0] == got[1] {
+		t.Fatal("dua Acquire paralel mendapat sesi yang sama")
+	}
+	if got[0] != idle && got[1] != idle {
+		t.Fatal("sesi idle tidak dipakai ulang")
+	}
+
+	mu.Lock()
+	if *launched != 2 {
+		t.Errorf("browser dibuat %d kali, harus 2 (awal + satu untuk Acquire kedua)", *launched)
+	}
+	mu.Unlock()
+
+	// Kedua sesi tercatat dipakai sehingga Release mengembalikan keduanya ke pool
+	p.mu.Lock()
+	inUse := len(p.inUse)
+	p.mu.Unlock()
+	if inUse != 2 {
+		t.Fatalf("sesi dipakai = %d, harus 2", inUse)
+	}
+
+	p.Release(got[0], nil)
+	p.Release(got[1], nil)
+	if n := len(p.idle["akun"]); n != 2 {
+		t.Fatalf("sesi idle = %d, harus 2", n)
+	}
+}
+
+func TestSessionPoolIdleSessionTrackedDuringRevive(t *testing.T) {
+	p, _, _ := newTestSessionPool(t)
+
+	idle, err := p.Acquire("order-0", nil, "akun", "rahasia")
+	if err != nil {
+		t.Fatalf("Acquire awal: %v", err)
+	}
+	p.Release(idle, nil)
+
+	// Selama health check, sesi sudah tercatat dipakai (ikut ditutup oleh Close)
+	tracked := make(chan bool, 1)
+	p.revive = func(svc *MitraHiggsService, key string, cfg *MitraHiggsConfig, username, password string) error {
+		p.mu.Lock()
+		ps, ok := p.inUse[svc]
+		p.mu.Unlock()
+		tracked <- ok && ps.key == "order-1"
+		return nil
+	}
+
+	if _, err := p.Acquire("order-1", nil, "akun", "rahasia"); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if !<-tracked {
+		t.Fatal("sesi idle belum ditandai dipakai saat health check")
+	}
+}
+
+func TestSessionPoolDeadIdleSessionDiscarded(t *testing.T) {
+	p, mu, launched := newTestSessionPool(t)
+
+	idle, err := p.Acquire("order-0", nil, "akun", "rahasia")
+	if err != nil {
+		t.Fatalf("Acquire awal: %v", err)
+	}
+	p.Release(idle, nil)
+
+	p.revive = func(svc *MitraHiggsService, key string, cfg *MitraHiggsConfig, username, password string) error {
+		return errSessionDead
+	}
+
+	svc, err := p.Acquire("order-1", nil, "akun", "rahasia")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if svc == idle {
+		t.Fatal("sesi yang mati dipakai ulang")
+	}
+
+	mu.Lock()
+	if *launched != 2 {
+		t.Errorf("browser dibuat %d kali, harus 2", *launched)
+	}
+	mu.Unlock()
+
+	p.mu.Lock()
+	_, stillTracked := p.inUse[idle]
+	p.mu.Unlock()
+	if stillTracked {
+		t.Fatal("sesi yang mati masih tercatat dipakai")
+	}
+}
+
+func TestSessionPoolAcquireAfterClose(t *testing.T) {
+	p, _, _ := newTestSessionPool(t)
+	p.Close()
+
+	if _, err := p.Acquire("order-1", nil, "akun", "rahasia"); !errors.Is(err, ErrSessionPoolClosed) {
+		t.Fatalf("err = %v, harus ErrSessionPoolClosed", err)
+	}
+}
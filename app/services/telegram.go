@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrTelegramDisabled: TELEGRAM_BOT_TOKEN belum diset
+var ErrTelegramDisabled = errors.New("telegram bot belum dikonfigurasi")
+
+// TelegramClient mengirim pesan lewat Telegram Bot API. Dipakai bersama oleh
+// worker (notifikasi order) dan TelegramHandler (balasan command bot).
+type TelegramClient struct {
+	Token         string // TELEGRAM_BOT_TOKEN
+	AdminChatID   string // TELEGRAM_CHAT_ID, tujuan laporan admin
+	WebhookSecret string // TELEGRAM_WEBHOOK_SECRET, dikirim Telegram di header X-Telegram-Bot-Api-Secret-Token
+	BaseURL       string
+	HTTP          *http.Client
+}
+
+func NewTelegramClient() *TelegramClient {
+	return &TelegramClient{
+		Token:         os.Getenv("TELEGRAM_BOT_TOKEN"),
+		AdminChatID:   os.Getenv("TELEGRAM_CHAT_ID"),
+		WebhookSecret: os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+		BaseURL:       "https://api.telegram.org",
+		HTTP:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// HasAdmin mengecek apakah laporan admin bisa dikirim
+func (t *TelegramClient) HasAdmin() bool {
+	return t.Token != "" && t.AdminChatID != ""
+}
+
+// Send mengirim pesan HTML ke satu chat dan menunggu respon Telegram
+func (t *TelegramClient) Send(ctx context.Context, chatID, messageHTML string) error {
+	if t.Token == "" {
+		return ErrTelegramDisabled
+	}
+	if chatID == "" {
+		return errors.New("chat ID telegram kosong")
+	}
+
+	return t.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     messageHTML,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+}
+
+// SetWebhook mendaftarkan URL webhook bot beserta secret_token, sehingga setiap
+// update dari Telegram membawa header X-Telegram-Bot-Api-Secret-Token
+func (t *TelegramClient) SetWebhook(ctx context.Context, webhookURL string) error {
+	if t.Token == "" {
+		return ErrTelegramDisabled
+	}
+	if t.WebhookSecret == "" {
+		return errors.New("TELEGRAM_WEBHOOK_SECRET belum diset")
+	}
+
+	return t.call(ctx, "setWebhook", map[string]interface{}{
+		"url":          webhookURL,
+		"secret_token": t.WebhookSecret,
+	})
+}
+
+// call memanggil satu method Bot API dan memeriksa field "ok" di respon
+func (t *TelegramClient) call(ctx context.Context, method string, params map[string]interface{}) error {
+	payload, _ := json.Marshal(params)
+
+	url := fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(t.BaseURL, "/"), t.Token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.HTTP.Do(req)
+	if err != nil {
+		// Error net/http memuat URL lengkap (berisi token bot), jangan diteruskan ke log
+		return errors.New("request ke Telegram gagal")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("respon Telegram tidak valid (HTTP %d)", resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram menolak %s (HTTP %d): %s", method, resp.StatusCode, result.Description)
+	}
+	return nil
+}
+
+// SendAsync mengirim pesan di background; kegagalan hanya dicatat di log
+func (t *TelegramClient) SendAsync(chatID, messageHTML string) {
+	if t.Token == "" || chatID == "" {
+		return
+	}
+	go func() {
+		if err := t.Send(context.Background(), chatID, messageHTML); err != nil {
+			log.Printf("⚠️ Gagal kirim Telegram ke %s: %v", chatID, err)
+		}
+	}()
+}
+
+// NotifyAdmin mengirim laporan ke chat admin (TELEGRAM_CHAT_ID) di background
+func (t *TelegramClient) NotifyAdmin(messageHTML string) {
+	if !t.HasAdmin() {
+		return
+	}
+	t.SendAsync(t.AdminChatID, messageHTML)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TelegramLinkTTL: masa berlaku token /start untuk menghubungkan akun ke Telegram
+var TelegramLinkTTL = 10 * time.Minute
+
+// ErrTelegramLinkInvalid: token tidak dikenal, sudah dipakai, atau kedaluwarsa
+var ErrTelegramLinkInvalid = errors.New("token link telegram tidak valid atau kedaluwarsa")
+
+func telegramLinkKey(token string) string {
+	return fmt.Sprintf("telegram_link:%s", token)
+}
+
+// IssueTelegramLinkToken membuat token sekali pakai untuk deep link
+// "/start <token>". Token disimpan di Redis dan hangus setelah TelegramLinkTTL.
+func IssueTelegramLinkToken(ctx context.Context, rdb *redis.Client, userID string) (string, error) {
+	token := generateRandomKey(16) // 32 karakter hex, aman untuk parameter deep link Telegram
+	if err := rdb.Set(ctx, telegramLinkKey(token), userID, TelegramLinkTTL).Err(); err != nil {
+		return "", fmt.Errorf("gagal menyimpan token link telegram: %w", err)
+	}
+	return token, nil
+}
+
+// RedeemTelegramLinkToken menukar token dengan user ID pemiliknya. Token
+// langsung dihapus (GETDEL) sehingga tidak bisa dipakai dua kali.
+func RedeemTelegramLinkToken(ctx context.Context, rdb *redis.Client, token string) (string, error) {
+	userID, err := rdb.GetDel(ctx, telegramLinkKey(token)).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && userID == "") {
+		return "", ErrTelegramLinkInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// TelegramDeepLink membentuk link t.me untuk token link. Kosong jika
+// TELEGRAM_BOT_USERNAME belum diset (seller mengirim "/start <token>" manual).
+func TelegramDeepLink(token string) string {
+	bot := os.Getenv("TELEGRAM_BOT_USERNAME")
+	if bot == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/%s?start=%s", bot, token)
+}
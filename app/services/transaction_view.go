@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"gerbangapi/prisma/db"
+)
+
+// TransactionView adalah satu pembelian unit di supplier (baris SupplierTransaction)
+// dalam bentuk yang dikirim ke seller lewat history & webhook.
+type TransactionView struct {
+	SupplierOrderItemID string    `json:"supplier_order_item_id"`
+	Item                string    `json:"item"`
+	Sequence            int       `json:"sequence"`
+	PaymentURL          string    `json:"payment_url,omitempty"`
+	SupplierRef         string    `json:"supplier_ref,omitempty"`
+	Status              string    `json:"status"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// SupplierOrderWithTransactions adalah relasi yang perlu di-fetch agar
+// TransactionsOf bisa membaca ledger sebuah supplier order
+func SupplierOrderWithTransactions() db.SupplierOrderRelationWith {
+	return db.SupplierOrder.Items.Fetch().With(
+		db.SupplierOrderItem.SupplierProduct.Fetch(),
+		db.SupplierOrderItem.Transactions.Fetch(),
+	)
+}
+
+// TransactionsOf mengubah ledger supplier order (yang sudah di-fetch dengan
+// SupplierOrderWithTransactions) menjadi list berurutan per item & sequence
+func TransactionsOf(orders ...db.SupplierOrderModel) []TransactionView {
+	views := []TransactionView{}
+
+	for _, so := range orders {
+		for _, item := range so.Items() {
+			itemName := ""
+			if sp := item.SupplierProduct(); sp != nil {
+				itemName = sp.Name
+			}
+
+			txs := item.Transactions()
+			sort.Slice(txs, func(i, j int) bool { return txs[i].Sequence < txs[j].Sequence })
+
+			for _, tx := range txs {
+				paymentURL, _ := tx.PaymentURL()
+				ref, _ := tx.SupplierRef()
+				views = append(views, TransactionView{
+					SupplierOrderItemID: item.ID,
+					Item:                itemName,
+					Sequence:            tx.Sequence,
+					PaymentURL:          paymentURL,
+					SupplierRef:         ref,
+					Status:              tx.Status,
+					CreatedAt:           tx.CreatedAt,
+					UpdatedAt:           tx.UpdatedAt,
+				})
+			}
+		}
+	}
+	return views
+}
+
+// LoadTransactions membaca ledger transaksi satu supplier order
+func LoadTransactions(ctx context.Context, client *db.PrismaClient, supplierOrderID string) ([]TransactionView, error) {
+	order, err := client.SupplierOrder.FindUnique(
+		db.SupplierOrder.ID.Equals(supplierOrderID),
+	).With(
+		SupplierOrderWithTransactions(),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return TransactionsOf(*order), nil
+}
+
+// LegacySN menggabungkan URL/referensi transaksi dengan koma untuk field "sn"
+// (format lama, dipertahankan agar integrasi seller yang ada tidak rusak)
+func LegacySN(txs []TransactionView) string {
+	refs := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		if tx.PaymentURL != "" {
+			refs = append(refs, tx.PaymentURL)
+		} else if tx.SupplierRef != "" {
+			refs = append(refs, tx.SupplierRef)
+		}
+	}
+	return strings.Join(refs, ",")
+}
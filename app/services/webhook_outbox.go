@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gerbangapi/app/utils"
+	"gerbangapi/prisma/db"
+)
+
+// Status WebhookDelivery
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookDispatcherActor adalah identitas dispatcher di log percobaan
+const WebhookDispatcherActor = "system:webhook-dispatcher"
+
+var (
+	// Jeda sebelum percobaan ke-2, ke-3, dst (total 9 percobaan dalam ~16 jam).
+	// Setelah itu delivery ditandai failed dan hanya bisa dikirim ulang manual.
+	webhookRetrySchedule = []time.Duration{
+		30 * time.Second,
+		2 * time.Minute,
+		10 * time.Minute,
+		30 * time.Minute,
+		time.Hour,
+		2 * time.Hour,
+		4 * time.Hour,
+		8 * time.Hour,
+	}
+
+	// Lama klaim satu percobaan; jika proses mati di tengah pengiriman,
+	// delivery diambil lagi oleh dispatcher setelah klaim habis
+	webhookClaimLease = 2 * time.Minute
+
+	// Batas potongan body respon seller yang disimpan di log
+	webhookResponseSnippet int64 = 1024
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook delivery tidak ditemukan")
+	ErrWebhookBusy     = errors.New("webhook sedang dikirim, coba lagi sebentar")
+)
+
+// WebhookOutbox menyimpan setiap webhook seller ke tabel webhook_delivery
+// sebelum dikirim, sehingga callback tidak hilang saat server seller down.
+// Pengiriman ulang dijalankan dispatcher worker (DispatchDue).
+type WebhookOutbox struct {
+	client *db.PrismaClient
+	http   *http.Client
+}
+
+func NewWebhookOutbox(client *db.PrismaClient) *WebhookOutbox {
+	return &WebhookOutbox{
+		client: client,
+		http:   newWebhookHTTPClient(),
+	}
+}
+
+// newWebhookHTTPClient membuat HTTP client yang hanya mau terhubung ke IP publik.
+// Pengecekan di Dialer.Control berjalan setelah DNS resolve pada setiap koneksi
+// (termasuk redirect), sehingga DNS rebinding ke jaringan internal / metadata
+// cloud ikut tertolak. Proxy sengaja tidak dipakai agar IP tujuan yang dicek.
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: utils.WebhookDialControl,
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          50,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// WebhookFilter untuk daftar delivery (field kosong = tidak difilter)
+type WebhookFilter struct {
+	UserID  string
+	OrderID string
+	Status  string
+	Limit   int
+	Offset  int
+}
+
+// Enqueue mencatat event ke outbox lalu langsung mencoba mengirimnya di background
+func (o *WebhookOutbox) Enqueue(ctx context.Context, userID, targetURL, event, orderID string, payload interface{}) (*db.WebhookDeliveryModel, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []db.WebhookDeliverySetParam{
+		db.WebhookDelivery.NextAttemptAt.Set(time.Now()),
+	}
+	if orderID != "" {
+		params = append(params, db.WebhookDelivery.OrderID.Set(orderID))
+	}
+
+	delivery, err := o.client.WebhookDelivery.CreateOne(
+		db.WebhookDelivery.Event.Set(event),
+		db.WebhookDelivery.URL.Set(targetURL),
+		db.WebhookDelivery.Payload.Set(string(body)),
+		db.WebhookDelivery.User.Link(db.User.ID.Equals(userID)),
+		params...,
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if _, err := o.attempt(context.Background(), delivery, WebhookDispatcherActor, true); err != nil && !errors.Is(err, ErrWebhookBusy) {
+			log.Printf("⚠️ Gagal mengirim webhook %s: %v", delivery.ID, err)
+		}
+	}()
+	return delivery, nil
+}
+
+// DispatchDue mengirim delivery pending yang jadwalnya sudah tiba.
+// Return jumlah delivery yang dicoba.
+func (o *WebhookOutbox) DispatchDue(ctx context.Context, limit int) (int, error) {
+	due, err := o.client.WebhookDelivery.FindMany(
+		db.WebhookDelivery.Status.Equals(WebhookPending),
+		db.WebhookDelivery.NextAttemptAt.Lte(time.Now()),
+	).OrderBy(
+		db.WebhookDelivery.NextAttemptAt.Order(db.SortOrderAsc),
+	).Take(limit).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	attempted := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		_, err := o.attempt(ctx, &due[i], WebhookDispatcherActor, true)
+		if errors.Is(err, ErrWebhookBusy) {
+			continue // Sedang dikirim proses lain
+		}
+		if err != nil {
+			log.Printf("⚠️ Gagal mengirim webhook %s: %v", due[i].ID, err)
+		}
+		attempted++
+	}
+	return attempted, nil
+}
+
+// Redeliver mengirim ulang delivery saat itu juga (status apa pun) atas
+// permintaan admin / seller. userID tidak kosong = delivery harus milik seller tersebut.
+func (o *WebhookOutbox) Redeliver(ctx context.Context, id, userID, actor string) (*db.WebhookDeliveryModel, *db.WebhookAttemptModel, error) {
+	delivery, err := o.find(ctx, id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attempt, err := o.attempt(ctx, delivery, actor, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	delivery, err = o.client.WebhookDelivery.FindUnique(db.WebhookDelivery.ID.Equals(id)).Exec(ctx)
+	return delivery, attempt, err
+}
+
+// List mengembalikan delivery terbaru lebih dulu
+func (o *WebhookOutbox) List(ctx context.Context, f WebhookFilter) ([]db.WebhookDeliveryModel, error) {
+	var where []db.WebhookDeliveryWhereParam
+	if f.UserID != "" {
+		where = append(where, db.WebhookDelivery.UserID.Equals(f.UserID))
+	}
+	if f.OrderID != "" {
+		where = append(where, db.WebhookDelivery.OrderID.Equals(f.OrderID))
+	}
+	if f.Status != "" {
+		where = append(where, db.WebhookDelivery.Status.Equals(f.Status))
+	}
+
+	return o.client.WebhookDelivery.FindMany(where...).OrderBy(
+		db.WebhookDelivery.CreatedAt.Order(db.SortOrderDesc),
+	).Take(f.Limit).Skip(f.Offset).Exec(ctx)
+}
+
+// Get mengembalikan satu delivery beserta log percobaannya
+func (o *WebhookOutbox) Get(ctx context.Context, id, userID string) (*db.WebhookDeliveryModel, error) {
+	where := []db.WebhookDeliveryWhereParam{db.WebhookDelivery.ID.Equals(id)}
+	if userID != "" {
+		where = append(where, db.WebhookDelivery.UserID.Equals(userID))
+	}
+
+	delivery, err := o.client.WebhookDelivery.FindFirst(where...).With(
+		db.WebhookDelivery.AttemptLogs.Fetch().OrderBy(
+			db.WebhookAttempt.CreatedAt.Order(db.SortOrderAsc),
+		),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	return delivery, err
+}
+
+func (o *WebhookOutbox) find(ctx context.Context, id, userID string) (*db.WebhookDeliveryModel, error) {
+	where := []db.WebhookDeliveryWhereParam{db.WebhookDelivery.ID.Equals(id)}
+	if userID != "" {
+		where = append(where, db.WebhookDelivery.UserID.Equals(userID))
+	}
+
+	delivery, err := o.client.WebhookDelivery.FindFirst(where...).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	return delivery, err
+}
+
+// attempt mengklaim delivery, mengirimnya satu kali, mencatat hasilnya di
+// webhook_attempt, lalu menjadwalkan percobaan berikutnya jika gagal.
+// scheduled = true hanya mengirim delivery pending yang sudah jatuh tempo.
+func (o *WebhookOutbox) attempt(ctx context.Context, d *db.WebhookDeliveryModel, actor string, scheduled bool) (*db.WebhookAttemptModel, error) {
+	now := time.Now()
+
+	// Klaim (conditional update): attempts dipakai sebagai versi dan locked_until
+	// mencegah dua proses mengirim delivery yang sama bersamaan
+	where := []db.WebhookDeliveryWhereParam{
+		db.WebhookDelivery.ID.Equals(d.ID),
+		db.WebhookDelivery.Attempts.Equals(d.Attempts),
+		db.WebhookDelivery.Or(
+			db.WebhookDelivery.LockedUntil.IsNull(),
+			db.WebhookDelivery.LockedUntil.Lte(now),
+		),
+	}
+	if scheduled {
+		where = append(where,
+			db.WebhookDelivery.Status.Equals(WebhookPending),
+			db.WebhookDelivery.NextAttemptAt.Lte(now),
+		)
+	}
+
+	claimed, err := o.client.WebhookDelivery.FindMany(where...).Update(
+		db.WebhookDelivery.Attempts.Increment(1),
+		db.WebhookDelivery.LockedUntil.Set(now.Add(webhookClaimLease)),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if claimed.Count == 0 {
+		return nil, ErrWebhookBusy
+	}
+	number := d.Attempts + 1
+
+	code, latency, snippet, sendErr := o.send(ctx, d)
+
+	// 1. Log percobaan
+	logParams := []db.WebhookAttemptSetParam{}
+	if code > 0 {
+		logParams = append(logParams, db.WebhookAttempt.StatusCode.Set(code))
+	}
+	if snippet != "" {
+		logParams = append(logParams, db.WebhookAttempt.ResponseBody.Set(snippet))
+	}
+	if sendErr != nil {
+		logParams = append(logParams, db.WebhookAttempt.Error.Set(sendErr.Error()))
+	}
+
+	attempt, err := o.client.WebhookAttempt.CreateOne(
+		db.WebhookAttempt.Attempt.Set(number),
+		db.WebhookAttempt.LatencyMs.Set(int(latency.Milliseconds())),
+		db.WebhookAttempt.Actor.Set(actor),
+		db.WebhookAttempt.Delivery.Link(db.WebhookDelivery.ID.Equals(d.ID)),
+		logParams...,
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("⚠️ Gagal mencatat percobaan webhook %s: %v", d.ID, err)
+	}
+
+	// 2. Status delivery & jadwal berikutnya (klaim dilepas)
+	set := []db.WebhookDeliverySetParam{
+		db.WebhookDelivery.LockedUntil.Set(time.Now()),
+	}
+	if code > 0 {
+		set = append(set, db.WebhookDelivery.LastStatusCode.Set(code))
+	}
+
+	switch {
+	case sendErr == nil:
+		set = append(set,
+			db.WebhookDelivery.Status.Set(WebhookDelivered),
+			db.WebhookDelivery.DeliveredAt.Set(time.Now()),
+			db.WebhookDelivery.LastError.Set(""),
+		)
+		log.Printf("✅ Webhook %s terkirim ke %s (percobaan ke-%d)", d.ID, d.URL, number)
+	case number <= len(webhookRetrySchedule):
+		next := time.Now().Add(webhookRetrySchedule[number-1])
+		set = append(set,
+			db.WebhookDelivery.Status.Set(WebhookPending),
+			db.WebhookDelivery.NextAttemptAt.Set(next),
+			db.WebhookDelivery.LastError.Set(sendErr.Error()),
+		)
+		log.Printf("🔁 Webhook %s gagal (percobaan ke-%d: %v), dicoba lagi %s", d.ID, number, sendErr, next.Format("15:04:05"))
+	default:
+		set = append(set,
+			db.WebhookDelivery.Status.Set(WebhookFailed),
+			db.WebhookDelivery.LastError.Set(sendErr.Error()),
+		)
+		log.Printf("❌ Webhook %s gave up setelah %d percobaan: %v", d.ID, number, sendErr)
+	}
+
+	if _, err := o.client.WebhookDelivery.FindUnique(
+		db.WebhookDelivery.ID.Equals(d.ID),
+	).Update(set...).Exec(ctx); err != nil {
+		return attempt, err
+	}
+	return attempt, nil
+}
+
+// send mengirim payload satu kali, ditandatangani secret API key seller
+// (lihat utils.VerifyWebhook). Secret dibaca ulang setiap percobaan agar
+// percobaan setelah rotasi ikut memakai secret baru.
+func (o *WebhookOutbox) send(ctx context.Context, d *db.WebhookDeliveryModel) (int, time.Duration, string, error) {
+	secrets, err := WebhookSecrets(ctx, o.client, d.UserID)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("API key aktif seller tidak ditemukan: %v", err)
+	}
+
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("URL webhook tidak valid: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signatures := make([]string, len(secrets))
+	for i, secret := range secrets {
+		signatures[i] = utils.SignWebhook(secret, timestamp, body)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(utils.WebhookIDHeader, d.ID)
+	req.Header.Set(utils.WebhookTimestampHeader, timestamp)
+	req.Header.Set(utils.WebhookSignatureHeader, strings.Join(signatures, ","))
+
+	start := time.Now()
+	resp, err := o.http.Do(req)
+	latency := time.Since(start)
+	if errors.Is(err, utils.ErrWebhookAddress) {
+		return 0, latency, "", utils.ErrWebhookAddress // Tanpa detail IP / port tujuan
+	}
+	if err != nil {
+		return 0, latency, "", err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseSnippet))
+	snippet := strings.ToValidUTF8(string(raw), "")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, latency, snippet, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, latency, snippet, nil
+}
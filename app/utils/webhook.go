@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Header yang dikirim di setiap webhook ke seller
+const (
+	WebhookTimestampHeader = "X-Webhook-Timestamp" // Unix detik saat webhook dikirim
+	WebhookSignatureHeader = "X-Webhook-Signature" // "sha256=<hex>", dipisah koma jika lebih dari satu
+	WebhookIDHeader        = "X-Webhook-ID"        // Sama di setiap percobaan, untuk deduplikasi di sisi seller
+)
+
+var (
+	ErrWebhookURL       = errors.New("webhook_url harus URL http(s) absolut tanpa kredensial, tidak mengarah ke jaringan internal (maks 2048 karakter)")
+	ErrWebhookAddress   = errors.New("alamat webhook mengarah ke jaringan internal")
+	ErrWebhookTimestamp = errors.New("timestamp webhook tidak valid / kedaluwarsa")
+	ErrWebhookSignature = errors.New("signature webhook tidak cocok")
+)
+
+// ValidateWebhookURL memastikan URL callback seller bisa dipanggil server.
+// Host berupa IP internal / localhost langsung ditolak; hostname yang resolve ke
+// IP internal (termasuk DNS rebinding) ditolak saat koneksi oleh WebhookDialControl.
+func ValidateWebhookURL(raw string) error {
+	if len(raw) > 2048 {
+		return ErrWebhookURL
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrWebhookURL
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookURL
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !IsPublicAddress(ip) {
+		return ErrWebhookURL
+	}
+	return nil
+}
+
+// Rentang yang tidak tercakup helper netip tapi tetap bukan internet publik
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // CGNAT (termasuk metadata Alibaba 100.100.100.200)
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // Reserved + broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, bisa memetakan ke IPv4 internal
+	netip.MustParsePrefix("64:ff9b:1::/48"), // NAT64 lokal
+}
+
+// IsPublicAddress: false untuk loopback, private, link-local (termasuk metadata
+// cloud 169.254.169.254), multicast, unspecified, dan rentang reserved lain
+func IsPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookDialControl dipasang di net.Dialer.Control HTTP client webhook. Dipanggil
+// setelah DNS resolve untuk setiap koneksi (termasuk redirect), sehingga hostname
+// yang resolve ke IP internal ditolak walau lolos ValidateWebhookURL.
+func WebhookDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !IsPublicAddress(addrPort.Addr()) {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+// SignHMAC menghasilkan HMAC-SHA256 (hex) dari message, pasangan VerifyHMAC
+func SignHMAC(message, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignWebhook menghitung signature webhook: HMAC-SHA256(secret, timestamp + "." + body)
+func SignWebhook(secret, timestamp string, body []byte) string {
+	return "sha256=" + SignHMAC(timestamp+"."+string(body), secret)
+}
+
+// VerifyWebhook memverifikasi webhook dari GerbangAPI di sisi seller.
+//
+// Langkah (berlaku untuk bahasa apa pun):
+//  1. Ambil header X-Webhook-Timestamp dan X-Webhook-Signature.
+//  2. Tolak jika timestamp terpaut lebih dari tolerance dari jam server (cegah replay).
+//  3. Hitung hex(HMAC-SHA256(secret, timestamp + "." + raw body)), raw body apa
+//     adanya sebelum di-parse JSON.
+//  4. Terima jika salah satu nilai "sha256=..." di header cocok (constant-time).
+//
+// Selama masa transisi setelah rotasi secret, header berisi dua signature
+// (secret baru & lama) sehingga seller bisa mengganti secret kapan saja.
+//
+// Contoh:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	err := utils.VerifyWebhook(secret, r.Header.Get(utils.WebhookTimestampHeader),
+//		r.Header.Get(utils.WebhookSignatureHeader), body, 5*time.Minute)
+func VerifyWebhook(secret, timestamp, signatureHeader string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWebhookTimestamp
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrWebhookTimestamp
+	}
+
+	message := timestamp + "." + string(body)
+	for _, sig := range strings.Split(signatureHeader, ",") {
+		sig = strings.TrimSpace(sig)
+		if !strings.HasPrefix(sig, "sha256=") {
+			continue
+		}
+		if VerifyHMAC(message, secret, strings.TrimPrefix(sig, "sha256=")) {
+			return nil
+		}
+	}
+	return ErrWebhookSignature
+}
@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+
+	"github.com/google/uuid"
+)
+
+// Tahap pemrosesan order, dipakai reaper untuk menentukan apakah order yang
+// macet aman dimasukkan ulang ke antrian atau harus dicek manual
+const (
+	stageClaimed    = "claimed"    // Baru diklaim, belum menyentuh supplier
+	stageLogin      = "login"      // Membuka browser & login ke supplier
+	stagePurchasing = "purchasing" // Sudah mulai membeli, mungkin ada transaksi di supplier
+)
+
+var (
+	// Identitas worker ini, disimpan di supplier_order.worker_id saat klaim
+	workerID = resolveWorkerID()
+
+	// Lama lease klaim. Diperpanjang otomatis selama order masih diproses.
+	leaseDuration = envDuration("WORKER_LEASE_DURATION", 10*time.Minute)
+)
+
+func resolveWorkerID() string {
+	if id := os.Getenv("WORKER_ID"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+}
+
+// claimSupplierOrder mengambil alih order secara atomik (conditional update).
+// Hanya satu worker yang berhasil karena UPDATE dibatasi status='pending';
+// worker lain mendapat affected rows = 0 dan harus melewati order tersebut.
+// Order yang jadwal retry-nya (next_attempt_at) belum tiba juga tidak diklaim,
+// begitu pula order milik supplier yang sedang di-pause (job-nya dimasukkan
+// ulang oleh rescan setelah supplier di-resume).
+func claimSupplierOrder(ctx context.Context, states *orderstate.Machine, orderID string) (bool, error) {
+	now := time.Now()
+
+	return states.SupplierOrder(ctx, orderstate.SupplierChange{
+		OrderID: orderID,
+		From:    orderstate.Pending,
+		To:      orderstate.Processing,
+		Reason:  "diklaim worker",
+		Actor:   workerActor(),
+		Where: []db.SupplierOrderWhereParam{
+			db.SupplierOrder.Or(
+				db.SupplierOrder.NextAttemptAt.IsNull(),
+				db.SupplierOrder.NextAttemptAt.Lte(now),
+			),
+			db.SupplierOrder.Supplier.Where(db.Supplier.Paused.Equals(false)),
+		},
+		Set: []db.SupplierOrderSetParam{
+			db.SupplierOrder.Attempt.Increment(1),
+			db.SupplierOrder.Stage.Set(stageClaimed),
+			db.SupplierOrder.WorkerID.Set(workerID),
+			db.SupplierOrder.ClaimedAt.Set(now),
+			db.SupplierOrder.LeaseExpiresAt.Set(now.Add(leaseDuration)),
+		},
+	})
+}
+
+// transitionClaimedOrder mengubah status order yang sedang dipegang worker ini
+// (processing -> status berikutnya) lewat state machine
+func transitionClaimedOrder(ctx context.Context, states *orderstate.Machine, orderID, to, reason string, params ...db.SupplierOrderSetParam) (bool, error) {
+	return states.SupplierOrder(ctx, orderstate.SupplierChange{
+		OrderID: orderID,
+		From:    orderstate.Processing,
+		To:      to,
+		Reason:  reason,
+		Actor:   workerActor(),
+		Where:   []db.SupplierOrderWhereParam{db.SupplierOrder.WorkerID.Equals(workerID)},
+		Set:     params,
+	})
+}
+
+// workerActor adalah identitas worker di riwayat status order
+func workerActor() string {
+	return "worker:" + workerID
+}
+
+// updateClaimedOrder meng-update field non-status (stage, lease) order yang
+// masih dipegang worker ini. Perubahan status lewat transitionClaimedOrder.
+func updateClaimedOrder(ctx context.Context, dbClient *db.PrismaClient, orderID string, params ...db.SupplierOrderSetParam) (bool, error) {
+	result, err := dbClient.SupplierOrder.FindMany(
+		db.SupplierOrder.ID.Equals(orderID),
+		db.SupplierOrder.Status.Equals(orderstate.Processing),
+		db.SupplierOrder.WorkerID.Equals(workerID),
+	).Update(params...).Exec(ctx)
+
+	if err != nil {
+		return false, err
+	}
+	return result.Count == 1, nil
+}
+
+// setStage mencatat tahap pemrosesan order yang sedang dipegang worker ini.
+// Return false jika order sudah tidak dipegang worker ini (lease diambil alih).
+func setStage(ctx context.Context, dbClient *db.PrismaClient, orderID, stage string) (bool, error) {
+	return updateClaimedOrder(ctx, dbClient, orderID,
+		db.SupplierOrder.Stage.Set(stage),
+		db.SupplierOrder.LeaseExpiresAt.Set(time.Now().Add(leaseDuration)),
+	)
+}
+
+// keepLeaseAlive memperpanjang lease secara berkala sampai ctx selesai. Jika
+// order sudah tidak dipegang worker ini, lost dipanggil agar pemrosesan order
+// (termasuk pembelian di supplier) ikut dibatalkan.
+func keepLeaseAlive(ctx context.Context, dbClient *db.PrismaClient, orderID string, lost context.CancelFunc) {
+	ticker := time.NewTicker(leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := updateClaimedOrder(ctx, dbClient, orderID,
+				db.SupplierOrder.LeaseExpiresAt.Set(time.Now().Add(leaseDuration)),
+			)
+			if err != nil {
+				log.Printf("⚠️ Gagal perpanjang lease order %s: %v", orderID, err)
+			} else if !ok {
+				log.Printf("⚠️ Order %s sudah tidak dipegang worker ini (%s), pemrosesan dibatalkan", orderID, workerID)
+				lost()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envDuration membaca durasi dari ENV (format Go: "30s", "5m"), fallback ke default
+func envDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️ ENV %s tidak valid (%q), memakai default %s", key, val, def)
+		return def
+	}
+	return d
+}
+
+// envInt membaca angka dari ENV, fallback ke default
+func envInt(key string, def int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		log.Printf("⚠️ ENV %s tidak valid (%q), memakai default %d", key, val, def)
+		return def
+	}
+	return n
+}
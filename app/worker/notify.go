@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/driver"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+)
+
+// Kode status di payload webhook seller
+const (
+	statusCodeInProgress = 0 // Masih diproses: pending, processing, manual_review
+	statusCodeSuccess    = 1 // Transaksi terbit di supplier: awaiting_payment, paid, delivered
+	statusCodeEnded      = 2 // Berakhir tanpa item lengkap: failed, expired, partial, cancelled, refunded
+)
+
+// statusCode memetakan status order ke status_code webhook seller
+func statusCode(status string) int {
+	switch status {
+	case orderstate.Pending, orderstate.Processing, orderstate.ManualReview:
+		return statusCodeInProgress
+	case orderstate.Failed, orderstate.Expired, orderstate.Partial, orderstate.Cancelled, orderstate.Refunded:
+		return statusCodeEnded
+	}
+	return statusCodeSuccess
+}
+
+// sellerNotices adalah judul & pesan notifikasi seller per status order
+var sellerNotices = map[string]struct{ title, message string }{
+	orderstate.Pending:         {"🕒 TRANSAKSI MENUNGGU", "Order masuk antrian dan akan segera diproses"},
+	orderstate.Processing:      {"⏳ TRANSAKSI DIPROSES", "Order sedang diproses di supplier"},
+	orderstate.AwaitingPayment: {"📦 TRANSAKSI BERHASIL", "Transaksi berhasil, silakan lakukan pembayaran melalui URL terlampir"},
+	orderstate.Paid:            {"💳 PEMBAYARAN DITERIMA", "Pembayaran diterima, item sedang dikirim"},
+	orderstate.Delivered:       {"✅ ITEM TERKIRIM", "Transaksi berhasil, item sudah terkirim ke tujuan"},
+	orderstate.Expired:         {"⌛ PEMBAYARAN KEDALUWARSA", "Batas waktu pembayaran habis, transaksi dibatalkan"},
+	orderstate.Partial:         {"⚠️ TRANSAKSI SEBAGIAN", "Hanya sebagian item yang berhasil diproses"},
+	orderstate.Failed:          {"❌ TRANSAKSI GAGAL", "Transaksi gagal diproses"},
+	orderstate.ManualReview:    {"🔍 TRANSAKSI DICEK ADMIN", "Status transaksi sedang dicek manual oleh admin"},
+	orderstate.Cancelled:       {"🚫 TRANSAKSI DIBATALKAN", "Transaksi dibatalkan oleh admin"},
+	orderstate.Refunded:        {"↩️ DANA DIKEMBALIKAN", "Dana transaksi dikembalikan"},
+}
+
+// sellerReason menerjemahkan penyebab kegagalan menjadi alasan yang aman
+// ditampilkan ke seller (tanpa detail internal: nama supplier, selector, error mentah)
+func sellerReason(cause error) string {
+	switch {
+	case errors.Is(cause, driver.ErrInvalidBuyer):
+		return "ID tujuan tidak ditemukan, periksa kembali ID tujuan"
+	case errors.Is(cause, driver.ErrPurchaseUncertain),
+		errors.Is(cause, errUnitNotRecorded):
+		return "Status pembelian di supplier tidak pasti, silakan hubungi admin"
+	case errors.Is(cause, driver.ErrLoginRejected),
+		errors.Is(cause, driver.ErrCredentialsMissing),
+		errors.Is(cause, driver.ErrNoDriver),
+		errors.Is(cause, driver.ErrNotSupported):
+		return "Supplier sedang mengalami gangguan, silakan coba lagi nanti"
+	}
+	return "Transaksi gagal diproses supplier, silakan coba lagi nanti"
+}
+
+// optionalTime mengubah field opsional Prisma menjadi pointer (nil = null di JSON)
+func optionalTime(t time.Time, ok bool) *time.Time {
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+// NotifyOrderStatus mengirim notifikasi perubahan status order ke seller:
+// Telegram personal dan webhook (lewat outbox). reason adalah alasan yang aman
+// untuk seller (kosong jika tidak ada). adminReport = pesan yang sama juga
+// dikirim ke chat admin.
+func (p *Pool) NotifyOrderStatus(ctx context.Context, internalOrderID, status, reason string, adminReport bool) {
+	internalOrder, err := p.DB.InternalOrder.FindUnique(
+		db.InternalOrder.ID.Equals(internalOrderID),
+	).With(
+		db.InternalOrder.Product.Fetch(),
+		db.InternalOrder.User.Fetch(),
+		db.InternalOrder.SupplierOrders.Fetch().With(
+			services.SupplierOrderWithTransactions(),
+		),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("⚠️ Gagal membaca internal order %s untuk notifikasi: %v", internalOrderID, err)
+		return
+	}
+
+	notice, ok := sellerNotices[status]
+	if !ok {
+		notice.title, notice.message = "ℹ️ STATUS TRANSAKSI", "Status transaksi berubah menjadi "+status
+	}
+
+	transactions := services.TransactionsOf(internalOrder.SupplierOrders()...)
+	productName := internalOrder.Product().Name
+	tanggal := time.Now().Format("02 Jan 2006 15:04")
+
+	// Tautan pembayaran hanya relevan saat menunggu pembayaran
+	var urlLinks string
+	if status == orderstate.AwaitingPayment {
+		if len(transactions) == 1 && transactions[0].PaymentURL != "" {
+			urlLinks = fmt.Sprintf("\n🔗 <a href=\"%s\">Klik untuk bayar</a>", html.EscapeString(transactions[0].PaymentURL))
+		} else {
+			for idx, tx := range transactions {
+				if tx.PaymentURL != "" {
+					urlLinks += fmt.Sprintf("\n🔗 <a href=\"%s\">Bayar Bagian %d</a>", html.EscapeString(tx.PaymentURL), idx+1)
+				}
+			}
+		}
+	}
+
+	var reasonLine string
+	if reason != "" {
+		reasonLine = fmt.Sprintf("\n<b>Keterangan:</b> %s", html.EscapeString(reason))
+	}
+
+	// Semua nilai dinamis di-escape: Telegram memakai parse_mode HTML, dan nama
+	// produk / ID tujuan / URL bisa berisi karakter seperti < & "
+	msg := fmt.Sprintf(`
+<b>%s</b>
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<b>Detail Produk:</b>
+🔹 %s
+
+📍 <b>Tujuan:</b> <code>%s</code>%s
+<b>Tanggal:</b> %s
+<b>Status:</b> <pre>%s</pre>%s
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<i>Ref ID: %s</i>
+`, html.EscapeString(notice.title), html.EscapeString(productName), html.EscapeString(internalOrder.BuyerUID),
+		urlLinks, tanggal, html.EscapeString(notice.message), reasonLine, html.EscapeString(internalOrder.ID))
+
+	if adminReport {
+		p.Telegram.NotifyAdmin("<b>[ADMIN REPORT]</b>\n" + msg)
+	}
+
+	user, ok := internalOrder.User()
+	if !ok || user == nil {
+		return
+	}
+
+	if userChatID, okID := user.TelegramChatID(); okID && userChatID != "" {
+		p.Telegram.SendAsync(userChatID, msg)
+	}
+
+	// Callback khusus order (dari request order) didahulukan dari webhook_url profil,
+	// agar platform dengan banyak toko dalam satu API key bisa merutekan callback
+	url, okURL := internalOrder.WebhookURL()
+	if !okURL || url == "" {
+		url, okURL = user.WebhookURL()
+	}
+	if okURL && url != "" {
+		webhookPayload := map[string]interface{}{
+			"seller_id":    user.ID,
+			"message_type": webhookEvent,
+			"timestamp":    tanggal,
+			"data": map[string]interface{}{
+				"trx_id":       internalOrder.ID,
+				"ref_id":       internalOrder.ID,
+				"product_name": productName,
+				"code":         internalOrder.ProductID,
+				"price":        internalOrder.Product().Price,
+				"status":       status,
+				"status_code":  statusCode(status),
+				"sn":           services.LegacySN(transactions),
+				"destination":  internalOrder.BuyerUID,
+				"transactions": transactions,
+				"message":      notice.message,
+				"reason":       reason,
+
+				"payment_expires_at": optionalTime(internalOrder.PaymentExpiresAt()),
+			},
+		}
+		p.queueWebhook(ctx, user.ID, url, internalOrder.ID, webhookPayload)
+	}
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"testing"
+
+	"gerbangapi/app/services/orderstate"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{orderstate.Pending, statusCodeInProgress},
+		{orderstate.Processing, statusCodeInProgress},
+		{orderstate.ManualReview, statusCodeInProgress},
+		{orderstate.AwaitingPayment, statusCodeSuccess},
+		{orderstate.Paid, statusCodeSuccess},
+		{orderstate.Delivered, statusCodeSuccess},
+		{orderstate.Success, statusCodeSuccess},
+		{orderstate.Failed, statusCodeEnded},
+		{orderstate.Expired, statusCodeEnded},
+		{orderstate.Partial, statusCodeEnded},
+		{orderstate.Cancelled, statusCodeEnded},
+		{orderstate.Refunded, statusCodeEnded},
+	}
+
+	for _, tt := range tests {
+		if got := statusCode(tt.status); got != tt.want {
+			t.Errorf("statusCode(%s) = %d, harus %d", tt.status, got, tt.want)
+		}
+	}
+}
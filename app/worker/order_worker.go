@@ -0,0 +1,486 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"time"
+
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/driver"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+)
+
+// processSupplierOrder mengeksekusi satu supplier order yang diambil dari antrian
+func (p *Pool) processSupplierOrder(ctx context.Context, supplier *db.SupplierModel, orderID string) error {
+	// =================================================================
+	// LANGKAH A: Ambil Supplier Order dari antrian
+	// =================================================================
+	supplierOrder, err := p.DB.SupplierOrder.FindUnique(
+		db.SupplierOrder.ID.Equals(orderID),
+	).Exec(ctx)
+
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			log.Printf("⚠️ Supplier order %s tidak ada di DB, job dibuang", orderID)
+			return nil
+		}
+		return fmt.Errorf("failed to fetch supplier order: %v", err)
+	}
+
+	// =================================================================
+	// LANGKAH B: Klaim Order secara atomik (pending -> processing)
+	// =================================================================
+	claimed, err := claimSupplierOrder(ctx, p.States, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to claim order %s: %v", orderID, err)
+	}
+	if !claimed {
+		log.Printf("⏭️ Order #%s sudah diklaim worker lain / bukan pending, dilewati", orderID)
+		return nil
+	}
+
+	log.Printf("🔥 Processing Order #%s (worker: %s)", orderID, workerID)
+
+	// Internal order ikut 'processing'. Seller cukup dinotifikasi sekali di
+	// percobaan pertama, bukan di setiap retry.
+	p.setInternalStatus(ctx, supplierOrder.InternalOrderID, orderstate.Processing, "diproses worker", workerActor())
+	if supplierOrder.Attempt == 0 {
+		p.NotifyOrderStatus(ctx, supplierOrder.InternalOrderID, orderstate.Processing, "", false)
+	}
+
+	// Perpanjang lease selama order diproses. orderCtx dipakai untuk semua
+	// interaksi dengan supplier dan dibatalkan jika lease hilang (order diambil
+	// alih reaper), sehingga pembelian tidak berjalan dobel dengan worker lain.
+	orderCtx, cancelOrder := context.WithCancel(ctx)
+	defer cancelOrder()
+	go keepLeaseAlive(orderCtx, p.DB, orderID, cancelOrder)
+
+	// =================================================================
+	// LANGKAH C: Ambil Data Lengkap (Internal Order + User)
+	// =================================================================
+
+	internalOrder, err := p.DB.InternalOrder.FindUnique(
+		db.InternalOrder.ID.Equals(supplierOrder.InternalOrderID),
+	).With(
+		db.InternalOrder.Product.Fetch(),
+		db.InternalOrder.User.Fetch(),
+		db.InternalOrder.PaymentType.Fetch(),
+	).Exec(ctx)
+
+	if err != nil {
+		p.failOrder(ctx, supplierOrder, permanent(errors.New("Internal Order Not Found")))
+		return nil
+	}
+
+	// === [PERBAIKAN] Ambil Item Detail TANPA LIMIT 1 ===
+	var items []map[string]interface{}
+	err = p.DB.Prisma.QueryRaw(
+		`SELECT soi.id, sp.supplier_product_id, soi.quantity, soi.completed_qty 
+		 FROM supplier_order_item soi
+		 JOIN supplier_product sp ON soi.supplier_product_id = sp.id
+		 WHERE soi.supplier_order_id = ?
+		 ORDER BY soi.created_at, soi.id`, // LIMIT 1 dihapus agar semua bahan campuran terbaca
+		supplierOrder.ID,
+	).Exec(ctx, &items)
+
+	// Gagal baca DB bersifat sementara: order dijadwalkan ulang, bukan 'failed'
+	if err != nil {
+		p.failOrder(ctx, supplierOrder, fmt.Errorf("Load Items Failed: %w", err))
+		return nil
+	}
+	if len(items) == 0 {
+		p.failOrder(ctx, supplierOrder, permanent(errors.New("No items found for this order")))
+		return nil
+	}
+
+	// =================================================================
+	// LANGKAH D: Eksekusi Order lewat Driver Supplier
+	// =================================================================
+
+	drv, err := p.Drivers.Get(supplier)
+	if err != nil {
+		p.failOrder(ctx, supplierOrder, permanent(err))
+		return nil
+	}
+
+	if owned, err := setStage(ctx, p.DB, orderID, stageLogin); err != nil || !owned {
+		log.Printf("⚠️ Order #%s dihentikan sebelum login, kepemilikan tidak terkonfirmasi (err: %v)", orderID, err)
+		return nil
+	}
+
+	// Artefak kegagalan (screenshot, HTML, trace) disimpan per supplier order
+	orderCtx = driver.WithArtifactKey(orderCtx, orderID)
+
+	// Login / ambil sesi yang sudah login (health check di dalam driver)
+	session, err := drv.Login(orderCtx)
+	if err != nil {
+		if errors.Is(err, driver.ErrLoginRejected) || errors.Is(err, driver.ErrCredentialsMissing) {
+			err = permanent(err)
+		}
+		p.failOrder(ctx, supplierOrder, fmt.Errorf("Login Failed: %w", err))
+		return nil
+	}
+
+	// Sesi dikembalikan ke driver; jika order gagal sesinya di-recycle
+	var orderErr error
+	defer func() { session.Release(orderErr) }()
+
+	paymentCode := "40" // Default ke QRIS
+	if pt, ok := internalOrder.PaymentType(); ok && pt != nil {
+		paymentCode = pt.Code
+	}
+
+	// Titik terakhir sebelum menyentuh supplier: jika order sudah diambil alih,
+	// jangan membeli (worker lain yang akan memprosesnya)
+	if owned, err := setStage(ctx, p.DB, orderID, stagePurchasing); err != nil || !owned || orderCtx.Err() != nil {
+		log.Printf("⚠️ Order #%s dihentikan sebelum pembelian, kepemilikan tidak terkonfirmasi (err: %v)", orderID, err)
+		return nil
+	}
+
+	// === [PERBAIKAN] Looping untuk Setiap Bahan Baku di dalam Resep ===
+	// Unit yang sudah sukses di attempt sebelumnya dilewati (resume), sehingga
+	// retry hanya membeli unit yang belum selesai.
+	for i, item := range items {
+		itemID := item["id"].(string)
+		productHTMLID := item["supplier_product_id"].(string)
+
+		// Parse Quantity
+		repeatCount := rawInt(item["quantity"], 1)
+		completed := rawInt(item["completed_qty"], 0)
+
+		remaining := repeatCount - completed
+		if remaining <= 0 {
+			log.Printf("⏭️ [Mix %d/%d] Item %s sudah selesai (%d/%d), dilewati", i+1, len(items), productHTMLID, completed, repeatCount)
+			continue
+		}
+
+		log.Printf("🛒 [Mix %d/%d] Placing order for Player: %s, Item ID: %s, Qty: %d (sisa %d, supplier: %s)", i+1, len(items), internalOrder.BuyerUID, productHTMLID, repeatCount, remaining, supplier.Code)
+
+		// Unit yang gagal dicatat menghentikan pembelian (orderCtx dibatalkan)
+		var recordErr error
+		_, err := session.PlaceOrder(orderCtx, driver.OrderRequest{
+			BuyerUID:    internalOrder.BuyerUID,
+			ProductID:   productHTMLID,
+			Quantity:    remaining,
+			PaymentCode: paymentCode,
+			OnUnit: func(unit driver.Unit) {
+				if recordErr != nil {
+					return
+				}
+				completed++
+				if err := recordUnit(ctx, p.DB, itemID, completed, unit); err != nil {
+					recordErr = err
+					cancelOrder()
+				}
+			},
+		})
+
+		if recordErr != nil {
+			// Unit sudah terbeli tapi tidak tercatat: jangan retry, serahkan ke admin
+			orderErr = recordErr
+			log.Printf("❌ Order #%s: %v", orderID, recordErr)
+			p.failOrder(ctx, supplierOrder, permanent(recordErr))
+			return nil
+		}
+		if err != nil && orderCtx.Err() != nil && ctx.Err() == nil {
+			// Lease hilang di tengah pembelian: unit yang sudah sukses tercatat,
+			// status order diurus pemegang baru / reaper
+			orderErr = err
+			log.Printf("⚠️ Order #%s dibatalkan di tengah pembelian karena lease hilang: %v", orderID, err)
+			return nil
+		}
+		if err != nil {
+			// Jangan retry jika ID player salah atau hasil pembelian tidak pasti.
+			// Unit yang sudah sukses tercatat, retry melanjutkan dari unit berikutnya.
+			if errors.Is(err, driver.ErrInvalidBuyer) || errors.Is(err, driver.ErrPurchaseUncertain) {
+				err = permanent(err)
+			}
+			orderErr = err
+			p.failOrder(ctx, supplierOrder, fmt.Errorf("Place Order Failed on item %s: %w", productHTMLID, err))
+			return nil
+		}
+
+		// Beri jeda antar bahan baku jika ada lebih dari 1 bahan (dari konfigurasi
+		// driver). Shutdown / lease hilang menghentikan order tanpa menunggu jeda.
+		if i < len(items)-1 && !sleepCtx(orderCtx, driver.ItemDelay(drv)) {
+			log.Printf("⚠️ Order #%s dihentikan di antara bahan baku (shutdown / lease hilang)", orderID)
+			return nil
+		}
+	}
+
+	// =================================================================
+	// LANGKAH E: Sukses & Notifikasi
+	// =================================================================
+
+	// Ledger transaksi per unit (termasuk unit dari attempt sebelumnya)
+	transactions, err := services.LoadTransactions(ctx, p.DB, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to load transactions of order %s: %v", orderID, err)
+	}
+	sn := services.LegacySN(transactions)
+
+	// URL pembayaran terbit -> awaiting_payment (dipantau payment poller),
+	// item yang langsung terkirim (sellCard) -> delivered
+	status := orderPaymentStatus(transactions)
+	if status == "" {
+		status = driver.PaymentDelivered
+	}
+
+	owned, err := transitionClaimedOrder(ctx, p.States, orderID, status,
+		fmt.Sprintf("pembelian selesai (%d unit)", len(transactions)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark order %s %s: %v", orderID, status, err)
+	}
+	if !owned {
+		log.Printf("⚠️ Order #%s sudah diambil alih worker lain, hasil tidak disimpan", orderID)
+		return nil
+	}
+	p.setInternalStatus(ctx, supplierOrder.InternalOrderID, status, "pembelian di supplier selesai", workerActor())
+
+	// URL pembayaran punya masa berlaku sesuai payment type; lewat dari itu
+	// order di-expire oleh job payment expiry
+	if status == driver.PaymentAwaiting {
+		p.setPaymentExpiry(ctx, internalOrder)
+	}
+
+	log.Printf("✅ Order #%s Success (%s)! %d transaksi: %s", orderID, status, len(transactions), sn)
+
+	// --- Siapkan Data Notifikasi Admin ---
+	productName := internalOrder.Product().Name
+	tujuan := internalOrder.BuyerUID
+	tanggal := time.Now().Format("02 Jan 2006 15:04")
+	supplierName := supplier.Name
+
+	statusLabel := "SUCCESS (MENUNGGU PEMBAYARAN)"
+	if status == driver.PaymentDelivered {
+		statusLabel = "SUCCESS (ITEM TERKIRIM)"
+	}
+
+	// Buat tautan URL dinamis (Jika URL lebih dari 1, ubah kalimatnya)
+	var urlLinks string
+	if len(transactions) > 1 {
+		for idx, tx := range transactions {
+			if tx.PaymentURL != "" {
+				urlLinks += fmt.Sprintf("\n🔗 <a href=\"%s\">Bayar Bagian %d</a>", html.EscapeString(tx.PaymentURL), idx+1)
+			}
+		}
+	} else if len(transactions) == 1 && transactions[0].PaymentURL != "" {
+		urlLinks = fmt.Sprintf("\n🔗 <a href=\"%s\">Klik untuk bayar</a>", html.EscapeString(transactions[0].PaymentURL))
+	}
+
+	// --- Template Notifikasi Profesional ---
+	msg := fmt.Sprintf(`
+<b>📦 TRANSAKSI BERHASIL</b>
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<b>Detail Produk:</b>
+🔹 %s
+
+<b>Informasi Pengiriman:</b>
+📍 <b>Tujuan:</b> <code>%s</code>
+<b>Payment URL:</b> %s
+🏢 <b>Supplier:</b> %s
+
+<b>Tanggal:</b> %s
+<b>Status:</b> <pre>%s</pre>
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<i>Ref ID: %s</i>
+`, html.EscapeString(productName), html.EscapeString(tujuan), urlLinks, html.EscapeString(supplierName),
+		tanggal, statusLabel, html.EscapeString(supplierOrder.InternalOrderID))
+
+	// 1. Kirim ke ADMIN (Wajib)
+	p.Telegram.NotifyAdmin("<b>[ADMIN REPORT]</b>\n" + msg)
+
+	// 2. Kirim ke USER (Telegram personal & webhook)
+	p.NotifyOrderStatus(ctx, internalOrder.ID, status, "", false)
+
+	return nil
+}
+
+// ==========================================
+// HELPER FUNCTIONS
+// ==========================================
+
+// failOrder mencatat kegagalan order. Error sementara (transient) dijadwalkan
+// ulang dengan exponential backoff; order baru benar-benar 'failed' (dan
+// notifikasi dikirim) setelah retry habis atau error bersifat permanen.
+// Jika sebagian unit sudah terbeli, status akhirnya 'partial'.
+func (p *Pool) failOrder(ctx context.Context, order *db.SupplierOrderModel, cause error) {
+	orderID := order.ID
+	internalID := order.InternalOrderID
+	attempt := order.Attempt + 1 // attempt sudah di-increment saat klaim
+	reason := fmt.Sprintf("[attempt %d/%d] %s", attempt, maxAttempts, cause.Error())
+
+	// Lokasi artefak kegagalan (jika driver menyimpannya) ditautkan ke order
+	artifact := db.SupplierOrder.ArtifactPath.SetIfPresent(optionalString(driver.ArtifactPath(cause)))
+
+	if !isPermanent(cause) && attempt < maxAttempts {
+		nextAttempt := time.Now().Add(retryDelay(attempt))
+		log.Printf("🔁 Order %s gagal: %s (retry pada %s)", orderID, reason, nextAttempt.Format("15:04:05"))
+
+		owned, err := transitionClaimedOrder(ctx, p.States, orderID, orderstate.Pending, reason,
+			db.SupplierOrder.LastError.Set(reason),
+			db.SupplierOrder.NextAttemptAt.Set(nextAttempt),
+			artifact,
+		)
+		if err != nil || !owned {
+			log.Printf("⚠️ Jadwal retry order %s tidak disimpan (err: %v, owned: %v)", orderID, err, owned)
+			return
+		}
+		// Internal order kembali 'pending' tanpa notifikasi seller (retry otomatis)
+		p.setInternalStatus(ctx, internalID, orderstate.Pending, reason, workerActor())
+		if err := p.Queue.EnqueueAt(ctx, order.SupplierID, orderID, nextAttempt); err != nil {
+			// Tetap aman: fallback scan DB akan memasukkan ulang order ini
+			log.Printf("⚠️ Gagal menjadwalkan retry order %s di antrian: %v", orderID, err)
+		}
+		return
+	}
+
+	// Jika sebagian unit sudah terbeli di supplier, order ditandai 'partial'
+	// agar admin bisa menindaklanjuti (bukan 'failed' biasa). Request yang
+	// sudah terkirim tanpa jawaban jelas, atau unit terbeli yang gagal dicatat,
+	// ditandai 'manual_review': tidak boleh dianggap gagal / diulang.
+	status := orderstate.Failed
+	done, total := orderProgress(ctx, p.DB, orderID)
+	if done > 0 {
+		status = orderstate.Partial
+		reason = fmt.Sprintf("%s (terbeli %d/%d unit)", reason, done, total)
+	}
+	if errors.Is(cause, driver.ErrPurchaseUncertain) || errors.Is(cause, errUnitNotRecorded) {
+		status = orderstate.ManualReview
+	}
+
+	log.Printf("❌ Order %s %s: %s", orderID, status, reason)
+
+	owned, err := transitionClaimedOrder(ctx, p.States, orderID, status, reason,
+		db.SupplierOrder.LastError.Set(reason),
+		artifact,
+	)
+	if err != nil || !owned {
+		log.Printf("⚠️ Status gagal order %s tidak disimpan (err: %v, owned: %v)", orderID, err, owned)
+		return
+	}
+	p.setInternalStatus(ctx, internalID, status, reason, workerActor())
+
+	// Notif ke seller memakai alasan yang aman ditampilkan (tanpa detail internal)
+	notice := sellerReason(cause)
+	if status == orderstate.Partial {
+		notice = fmt.Sprintf("%s. Berhasil diproses %d dari %d unit", notice, done, total)
+	}
+	p.NotifyOrderStatus(ctx, internalID, status, notice, false)
+
+	// Notif Telegram Gagal ke ADMIN
+	if p.Telegram.HasAdmin() {
+		title := "❌ TRANSAKSI GAGAL"
+		switch status {
+		case orderstate.Partial:
+			title = "⚠️ TRANSAKSI SEBAGIAN (PARTIAL)"
+		case orderstate.ManualReview:
+			title = "🔍 TRANSAKSI PERLU DICEK MANUAL"
+		}
+		msg := fmt.Sprintf(`
+<b>%s</b>
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<b>ID Order:</b> <code>%s</code>
+<b>Penyebab:</b> <pre>%s</pre>
+<b>Internal ID:</b> %s
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬`, title, html.EscapeString(orderID), html.EscapeString(reason), html.EscapeString(internalID))
+
+		p.Telegram.NotifyAdmin(msg)
+	}
+}
+
+// setInternalStatus menyamakan status internal order (milik seller) dengan hasil supplier order
+func (p *Pool) setInternalStatus(ctx context.Context, internalID, status, reason, actor string) {
+	_, err := p.States.InternalOrder(ctx, orderstate.InternalChange{
+		OrderID: internalID,
+		To:      status,
+		Reason:  reason,
+		Actor:   actor,
+	})
+	if err != nil {
+		log.Printf("⚠️ Gagal update status internal order %s -> %s: %v", internalID, status, err)
+	}
+}
+
+// rawInt membaca angka dari hasil QueryRaw (bisa float64 / int64, atau string
+// untuk BIGINT seperti hasil COUNT)
+func rawInt(v interface{}, def int) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int64:
+		return int(n)
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// errUnitNotRecorded: unit sudah terbeli di supplier tetapi gagal dicatat ke
+// ledger. Order tidak boleh di-retry (unit akan terbeli dua kali), sehingga
+// diserahkan ke admin lewat manual_review.
+var errUnitNotRecorded = errors.New("unit terbeli tetapi gagal dicatat")
+
+// recordUnit mencatat satu unit yang sukses dibeli ke ledger SupplierTransaction
+// dan menaikkan progres item (dipakai untuk resume saat retry). Keduanya ditulis
+// dalam satu transaksi agar ledger dan completed_qty tidak pernah berbeda.
+func recordUnit(ctx context.Context, client *db.PrismaClient, itemID string, sequence int, unit driver.Unit) error {
+	createTx := client.SupplierTransaction.CreateOne(
+		db.SupplierTransaction.Sequence.Set(sequence),
+		db.SupplierTransaction.SupplierOrderItem.Link(db.SupplierOrderItem.ID.Equals(itemID)),
+		db.SupplierTransaction.PaymentURL.SetIfPresent(optionalString(unit.PaymentURL)),
+		db.SupplierTransaction.SupplierRef.SetIfPresent(optionalString(unit.SupplierRef)),
+		db.SupplierTransaction.Status.Set(unitStatus(unit)),
+	).Tx()
+
+	progressTx := client.SupplierOrderItem.FindUnique(
+		db.SupplierOrderItem.ID.Equals(itemID),
+	).Update(
+		db.SupplierOrderItem.CompletedQty.Increment(1),
+	).Tx()
+
+	if err := client.Prisma.Transaction(createTx, progressTx).Exec(ctx); err != nil {
+		return fmt.Errorf("%w: item %s #%d (ref: %s): %v", errUnitNotRecorded, itemID, sequence, unit.SupplierRef, err)
+	}
+	return nil
+}
+
+// unitStatus: unit dengan URL pembayaran menunggu dibayar, selain itu sudah terkirim
+func unitStatus(unit driver.Unit) string {
+	if unit.PaymentURL != "" {
+		return driver.PaymentAwaiting
+	}
+	return driver.PaymentDelivered
+}
+
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// orderProgress mengembalikan jumlah unit yang sudah sukses & total unit order
+func orderProgress(ctx context.Context, client *db.PrismaClient, orderID string) (int, int) {
+	items, err := client.SupplierOrderItem.FindMany(
+		db.SupplierOrderItem.SupplierOrderID.Equals(orderID),
+	).Exec(ctx)
+	if err != nil {
+		return 0, 0
+	}
+
+	done, total := 0, 0
+	for _, item := range items {
+		done += item.CompletedQty
+		total += item.Quantity
+	}
+	return done, total
+}
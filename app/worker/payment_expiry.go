@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gerbangapi/app/services/driver"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+)
+
+// Interval job mengecek order yang melewati batas waktu pembayaran
+var paymentExpiryInterval = envDuration("PAYMENT_EXPIRY_INTERVAL", time.Minute)
+
+// Masa berlaku URL pembayaran jika order tidak punya payment type
+const defaultPaymentTTLMinutes = 60
+
+const paymentExpiryActor = "system:payment-expiry"
+
+// setPaymentExpiry mengisi batas bayar internal order (sekarang + TTL payment type)
+func (p *Pool) setPaymentExpiry(ctx context.Context, internalOrder *db.InternalOrderModel) *time.Time {
+	ttl := defaultPaymentTTLMinutes
+	if pt, ok := internalOrder.PaymentType(); ok && pt != nil && pt.PaymentTTLMinutes > 0 {
+		ttl = pt.PaymentTTLMinutes
+	}
+	expiresAt := time.Now().Add(time.Duration(ttl) * time.Minute)
+
+	_, err := p.DB.InternalOrder.FindUnique(
+		db.InternalOrder.ID.Equals(internalOrder.ID),
+	).Update(
+		db.InternalOrder.PaymentExpiresAt.Set(expiresAt),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("⚠️ Gagal menyimpan batas bayar order %s: %v", internalOrder.ID, err)
+		return nil
+	}
+	return &expiresAt
+}
+
+// runPaymentExpiry meng-expire order yang URL pembayarannya sudah lewat batas waktu
+func (p *Pool) runPaymentExpiry(ctx context.Context) {
+	for sleepCtx(ctx, paymentExpiryInterval) {
+		p.expireUnpaidOrders(ctx)
+	}
+}
+
+func (p *Pool) expireUnpaidOrders(ctx context.Context) {
+	// Order legacy 'success' (sebelum ada tracking pembayaran) ikut disapu agar
+	// tidak sukses selamanya: dimasukkan ke alur pembayaran lalu di-expire
+	orders, err := p.DB.InternalOrder.FindMany(
+		db.InternalOrder.Status.In([]string{driver.PaymentAwaiting, orderstate.Success}),
+		db.InternalOrder.Or(
+			db.InternalOrder.PaymentExpiresAt.Lt(time.Now()),
+			db.InternalOrder.And(
+				db.InternalOrder.Status.Equals(orderstate.Success),
+				db.InternalOrder.PaymentExpiresAt.IsNull(),
+			),
+		),
+	).With(
+		db.InternalOrder.SupplierOrders.Fetch(),
+		db.InternalOrder.PaymentType.Fetch(),
+	).Exec(ctx)
+
+	if err != nil {
+		log.Printf("⚠️ Payment expiry gagal membaca order: %v", err)
+		return
+	}
+
+	for _, internalOrder := range orders {
+		if internalOrder.Status == orderstate.Success && !p.adoptLegacyOrder(ctx, &internalOrder) {
+			continue
+		}
+
+		for _, order := range internalOrder.SupplierOrders() {
+			if ctx.Err() != nil {
+				return
+			}
+			if order.Status != driver.PaymentAwaiting && order.Status != orderstate.Success {
+				continue
+			}
+
+			// Hanya unit yang masih menunggu pembayaran; unit yang sudah dibayar tetap diproses
+			result, err := p.DB.SupplierTransaction.FindMany(
+				db.SupplierTransaction.SupplierOrderItem.Where(
+					db.SupplierOrderItem.SupplierOrderID.Equals(order.ID),
+				),
+				db.SupplierTransaction.Status.Equals(driver.PaymentAwaiting),
+			).Update(
+				db.SupplierTransaction.Status.Set(driver.PaymentExpired),
+			).Exec(ctx)
+			if err != nil {
+				log.Printf("⚠️ Gagal expire transaksi order %s: %v", order.ID, err)
+				continue
+			}
+
+			log.Printf("⌛ Order %s melewati batas bayar, %d unit di-expire", order.ID, result.Count)
+
+			// Order legacy tanpa ledger tidak punya unit untuk diturunkan statusnya
+			if order.Status == orderstate.Success && !p.hasTransactions(ctx, order.ID) {
+				p.expireWithoutTransactions(ctx, &order)
+				continue
+			}
+
+			// Transisi status bersifat kondisional, sehingga notifikasi hanya terkirim sekali
+			p.syncOrderPaymentStatus(ctx, order.ID, "batas waktu pembayaran habis", paymentExpiryActor)
+		}
+	}
+}
+
+// adoptLegacyOrder memindahkan order legacy 'success' ke 'awaiting_payment' agar
+// unitnya dicek payment poller. Order tanpa batas bayar diberi batas baru (dihitung
+// dari sekarang) sehingga poller sempat mengecek unit yang sebenarnya sudah dibayar.
+// Return true jika batas bayar sudah lewat dan order bisa langsung di-expire.
+func (p *Pool) adoptLegacyOrder(ctx context.Context, internalOrder *db.InternalOrderModel) bool {
+	const reason = "order legacy dimasukkan ke tracking pembayaran"
+
+	for _, order := range internalOrder.SupplierOrders() {
+		if order.Status != orderstate.Success {
+			continue
+		}
+		if _, err := p.States.SupplierOrder(ctx, orderstate.SupplierChange{
+			OrderID: order.ID,
+			From:    orderstate.Success,
+			To:      orderstate.AwaitingPayment,
+			Reason:  reason,
+			Actor:   paymentExpiryActor,
+		}); err != nil {
+			log.Printf("⚠️ Gagal memindahkan order legacy %s: %v", order.ID, err)
+			return false
+		}
+	}
+	p.setInternalStatus(ctx, internalOrder.ID, orderstate.AwaitingPayment, reason, paymentExpiryActor)
+
+	expiresAt, ok := internalOrder.PaymentExpiresAt()
+	if !ok {
+		p.setPaymentExpiry(ctx, internalOrder)
+		return false
+	}
+	return expiresAt.Before(time.Now())
+}
+
+func (p *Pool) hasTransactions(ctx context.Context, supplierOrderID string) bool {
+	txs, err := p.DB.SupplierTransaction.FindMany(
+		db.SupplierTransaction.SupplierOrderItem.Where(
+			db.SupplierOrderItem.SupplierOrderID.Equals(supplierOrderID),
+		),
+	).Take(1).Exec(ctx)
+	return err != nil || len(txs) > 0 // Error: anggap punya ledger, jalur normal yang aman
+}
+
+// expireWithoutTransactions meng-expire order legacy yang tidak punya URL pembayaran tercatat
+func (p *Pool) expireWithoutTransactions(ctx context.Context, order *db.SupplierOrderModel) {
+	const reason = "batas waktu pembayaran habis (order legacy tanpa ledger)"
+
+	ok, err := p.States.SupplierOrder(ctx, orderstate.SupplierChange{
+		OrderID: order.ID,
+		From:    orderstate.AwaitingPayment,
+		To:      orderstate.Expired,
+		Reason:  reason,
+		Actor:   paymentExpiryActor,
+	})
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("⚠️ Gagal expire order legacy %s: %v", order.ID, err)
+		}
+		return
+	}
+	p.setInternalStatus(ctx, order.InternalOrderID, orderstate.Expired, reason, paymentExpiryActor)
+	p.NotifyOrderStatus(ctx, order.InternalOrderID, orderstate.Expired, "", true)
+}
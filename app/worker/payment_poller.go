@@ -0,0 +1,215 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/driver"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+)
+
+// Interval poller mengecek status pembayaran unit yang URL-nya sudah terbit
+var paymentPollInterval = envDuration("PAYMENT_POLL_INTERVAL", 2*time.Minute)
+
+const paymentPollerActor = "system:payment-poller"
+
+// Urutan status pembayaran unit; transisi hanya boleh maju
+var paymentRank = map[string]int{
+	driver.PaymentAwaiting:  0,
+	driver.PaymentPaid:      1,
+	driver.PaymentDelivered: 2,
+	driver.PaymentExpired:   2,
+}
+
+// runPaymentPoller mengecek secara berkala unit yang menunggu pembayaran / pengiriman
+func (p *Pool) runPaymentPoller(ctx context.Context) {
+	for sleepCtx(ctx, paymentPollInterval) {
+		p.pollPayments(ctx)
+	}
+}
+
+// pollPayments hanya mengecek unit dari order yang masih menunggu pembayaran /
+// pengiriman; order yang sudah di manual_review, refunded, dll tidak disentuh.
+func (p *Pool) pollPayments(ctx context.Context) {
+	txs, err := p.DB.SupplierTransaction.FindMany(
+		db.SupplierTransaction.Status.In([]string{driver.PaymentAwaiting, driver.PaymentPaid}),
+		db.SupplierTransaction.SupplierOrderItem.Where(
+			db.SupplierOrderItem.SupplierOrder.Where(
+				db.SupplierOrder.Status.In([]string{orderstate.AwaitingPayment, orderstate.Paid}),
+			),
+		),
+	).With(
+		db.SupplierTransaction.SupplierOrderItem.Fetch().With(
+			db.SupplierOrderItem.SupplierOrder.Fetch().With(
+				db.SupplierOrder.Supplier.Fetch(),
+			),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		log.Printf("⚠️ Payment poller gagal membaca transaksi: %v", err)
+		return
+	}
+
+	// Kelompokkan per supplier agar satu sesi login dipakai untuk banyak unit
+	suppliers := make(map[string]*db.SupplierModel)
+	bySupplier := make(map[string][]db.SupplierTransactionModel)
+	for _, tx := range txs {
+		order := tx.SupplierOrderItem().SupplierOrder()
+		suppliers[order.SupplierID] = order.Supplier()
+		bySupplier[order.SupplierID] = append(bySupplier[order.SupplierID], tx)
+	}
+
+	changed := make(map[string]bool) // supplier order ID yang unitnya berubah status
+	for supplierID, list := range bySupplier {
+		if ctx.Err() != nil {
+			return
+		}
+		p.checkSupplierPayments(ctx, suppliers[supplierID], list, changed)
+	}
+
+	for orderID := range changed {
+		p.syncOrderPaymentStatus(ctx, orderID, "status pembayaran di supplier berubah", paymentPollerActor)
+	}
+}
+
+func (p *Pool) checkSupplierPayments(ctx context.Context, supplier *db.SupplierModel, txs []db.SupplierTransactionModel, changed map[string]bool) {
+	drv, err := p.Drivers.Get(supplier)
+	if err != nil {
+		log.Printf("⚠️ Payment poller: %v", err)
+		return
+	}
+
+	// Pakai slot akun yang sama dengan worker agar sesi paralel tidak melebihi batas
+	sem := p.semaphore(supplier)
+	if !sem.Acquire(ctx) {
+		return
+	}
+	defer sem.Release()
+
+	session, err := drv.Login(ctx)
+	if err != nil {
+		log.Printf("⚠️ Payment poller gagal login %s: %v", supplier.Code, err)
+		return
+	}
+
+	var sessionErr error
+	defer func() { session.Release(sessionErr) }()
+
+	for _, tx := range txs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		paymentURL, _ := tx.PaymentURL()
+		ref, _ := tx.SupplierRef()
+
+		status, err := session.CheckStatus(ctx, driver.Unit{PaymentURL: paymentURL, SupplierRef: ref})
+		if errors.Is(err, driver.ErrNotSupported) {
+			continue // Unit ini tidak bisa dicek (misal tanpa URL pembayaran), unit lain tetap dicek
+		}
+		if err != nil {
+			log.Printf("⚠️ Gagal cek pembayaran transaksi %s: %v", tx.ID, err)
+			sessionErr = err
+			return
+		}
+
+		if paymentRank[status] <= paymentRank[tx.Status] {
+			continue
+		}
+
+		// Conditional update: hanya jika status belum diubah proses lain
+		result, err := p.DB.SupplierTransaction.FindMany(
+			db.SupplierTransaction.ID.Equals(tx.ID),
+			db.SupplierTransaction.Status.Equals(tx.Status),
+		).Update(
+			db.SupplierTransaction.Status.Set(status),
+		).Exec(ctx)
+		if err != nil {
+			log.Printf("⚠️ Gagal update status transaksi %s: %v", tx.ID, err)
+			continue
+		}
+		if result.Count > 0 {
+			log.Printf("💳 Transaksi %s: %s -> %s", tx.ID, tx.Status, status)
+			changed[tx.SupplierOrderItem().SupplierOrderID] = true
+		}
+	}
+}
+
+// orderPaymentStatus menurunkan status supplier order dari status unit-unitnya
+func orderPaymentStatus(txs []services.TransactionView) string {
+	counts := make(map[string]int)
+	for _, tx := range txs {
+		counts[tx.Status]++
+	}
+
+	switch {
+	case len(txs) == 0:
+		return ""
+	case counts[driver.PaymentAwaiting] > 0:
+		return driver.PaymentAwaiting
+	case counts[driver.PaymentDelivered] == len(txs):
+		return driver.PaymentDelivered
+	case counts[driver.PaymentExpired] == len(txs):
+		return driver.PaymentExpired
+	case counts[driver.PaymentExpired] > 0:
+		return orderstate.Partial // Sebagian dibayar, sebagian kedaluwarsa
+	}
+	return driver.PaymentPaid
+}
+
+// syncOrderPaymentStatus menyesuaikan status supplier order & internal order dengan
+// status unit, lalu mengirim notifikasi untuk setiap transisi yang dilewati
+// (misal awaiting_payment -> paid -> delivered).
+func (p *Pool) syncOrderPaymentStatus(ctx context.Context, supplierOrderID, reason, actor string) {
+	transactions, err := services.LoadTransactions(ctx, p.DB, supplierOrderID)
+	if err != nil {
+		log.Printf("⚠️ Gagal membaca transaksi order %s: %v", supplierOrderID, err)
+		return
+	}
+
+	target := orderPaymentStatus(transactions)
+	order, err := p.DB.SupplierOrder.FindUnique(
+		db.SupplierOrder.ID.Equals(supplierOrderID),
+	).Exec(ctx)
+	if err != nil || target == "" || target == order.Status {
+		return
+	}
+
+	steps := []string{target}
+	if order.Status == driver.PaymentAwaiting && target == driver.PaymentDelivered {
+		steps = []string{driver.PaymentPaid, driver.PaymentDelivered}
+	}
+
+	current := order.Status
+	for _, next := range steps {
+		// Order yang statusnya sudah tidak bisa mengikuti pembayaran (misal
+		// manual_review) dibiarkan; bukan error
+		if !orderstate.CanTransition(current, next) {
+			return
+		}
+		ok, err := p.States.SupplierOrder(ctx, orderstate.SupplierChange{
+			OrderID: supplierOrderID,
+			From:    current,
+			To:      next,
+			Reason:  reason,
+			Actor:   actor,
+		})
+		if err != nil {
+			log.Printf("⚠️ Gagal update status order %s (%s -> %s): %v", supplierOrderID, current, next, err)
+			return
+		}
+		if !ok {
+			return
+		}
+		p.setInternalStatus(ctx, order.InternalOrderID, next, reason, actor)
+
+		log.Printf("💳 Order %s: %s -> %s", supplierOrderID, current, next)
+		p.NotifyOrderStatus(ctx, order.InternalOrderID, next, "", true)
+		current = next
+	}
+}
@@ -0,0 +1,504 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/driver"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Pool mengelola sekumpulan worker per supplier.
+// Jumlah worker tiap supplier mengikuti Supplier.max_concurrency, dan setiap
+// akun supplier dibatasi semaphore agar sesi browser paralel tidak melebihi
+// batas yang ditoleransi supplier (batas berlaku per proses).
+type Pool struct {
+	DB    *db.PrismaClient
+	Redis *redis.Client
+	Queue *services.OrderQueue
+
+	mu         sync.Mutex
+	suppliers  map[string]*supplierWorkers
+	semaphores map[string]*accountSemaphore
+
+	// Driver integrasi per supplier (MitraHiggs, dll)
+	Drivers *driver.Manager
+
+	// Semua perubahan status order lewat state machine
+	States *orderstate.Machine
+
+	// Outbox webhook seller (dikirim ulang oleh dispatcher sampai berhasil)
+	Webhooks *services.WebhookOutbox
+
+	// Client bot Telegram (laporan admin & notifikasi personal seller)
+	Telegram *services.TelegramClient
+
+	// stopCtx dibatalkan saat shutdown dimulai: worker berhenti mengklaim order baru.
+	// workCtx dibatalkan saat batas waktu shutdown habis: order in-flight dihentikan.
+	stopCtx context.Context
+	stop    context.CancelFunc
+	workCtx context.Context
+	abort   context.CancelFunc
+	workers sync.WaitGroup
+}
+
+type supplierWorkers struct {
+	supplier atomic.Pointer[db.SupplierModel]
+	size     atomic.Int32 // jumlah worker yang diinginkan
+	busy     atomic.Int32 // worker yang sedang memproses order
+	slots    map[int]bool // index worker yang sedang hidup (dijaga Pool.mu)
+}
+
+// SupplierStats adalah ringkasan kondisi antrian & worker satu supplier
+type SupplierStats struct {
+	SupplierID     string `json:"supplier_id"`
+	SupplierCode   string `json:"supplier_code"`
+	SupplierName   string `json:"supplier_name"`
+	MaxConcurrency int    `json:"max_concurrency"`
+	Paused         bool   `json:"paused"`
+	Workers        int    `json:"workers"`
+	ActiveWorkers  int    `json:"active_workers"`
+	QueueDepth     int64  `json:"queue_depth"`
+	RetryScheduled int64  `json:"retry_scheduled"`
+	Processing     int    `json:"processing"`
+	Partial        int    `json:"partial"` // Order yang terbeli sebagian, perlu tindakan admin
+}
+
+// StartWorker memulai pool worker di background (Goroutine).
+// Worker menunggu job dari antrian Redis (blocking) alih-alih polling DB,
+// dan pool menjalankan fallback scan DB untuk memasukkan ulang order yatim.
+func StartWorker(dbClient *db.PrismaClient, redisClient *redis.Client, queue *services.OrderQueue, drivers *driver.Manager) *Pool {
+	log.Printf("🚀 Starting Order Worker Pool (Queue Mode, worker: %s)...", workerID)
+
+	p := &Pool{
+		DB:         dbClient,
+		Redis:      redisClient,
+		Queue:      queue,
+		suppliers:  make(map[string]*supplierWorkers),
+		semaphores: make(map[string]*accountSemaphore),
+		Drivers:    drivers,
+		States:     orderstate.New(dbClient),
+		Webhooks:   services.NewWebhookOutbox(dbClient),
+		Telegram:   services.NewTelegramClient(),
+	}
+	p.stopCtx, p.stop = context.WithCancel(context.Background())
+	p.workCtx, p.abort = context.WithCancel(context.Background())
+
+	p.background(func(context.Context) { p.run() })
+	return p
+}
+
+// background menjalankan loop latar belakang pool. Loop ikut dihitung di WaitGroup
+// yang sama dengan worker, sehingga Shutdown baru selesai setelah loop berhenti
+// memakai Redis & Prisma.
+func (p *Pool) background(loop func(ctx context.Context)) {
+	p.workers.Add(1)
+	go func() {
+		defer p.workers.Done()
+		loop(p.stopCtx)
+	}()
+}
+
+func (p *Pool) run() {
+	p.background(p.promoteRetries)
+	p.background(p.runReaper)
+	p.background(p.runPaymentPoller)
+	p.background(p.runPaymentExpiry)
+	p.background(p.runWebhookDispatcher)
+
+	// Perubahan supplier dari API admin (replica mana pun) memicu reload langsung
+	changes := p.Redis.Subscribe(p.stopCtx, driver.SupplierChangedChannel)
+	defer changes.Close()
+
+	for {
+		p.refreshSuppliers(p.stopCtx)
+
+		timer := time.NewTimer(pendingRescanInterval)
+		select {
+		case <-p.stopCtx.Done():
+			timer.Stop()
+			return
+		case msg := <-changes.Channel():
+			timer.Stop()
+			if msg != nil {
+				log.Printf("🔄 Supplier %s berubah, memuat ulang konfigurasi worker", msg.Payload)
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+// Shutdown menghentikan pool secara graceful: worker & loop latar belakang
+// berhenti mengambil pekerjaan baru, lalu ditunggu sampai order in-flight
+// selesai atau ctx habis. Jika batas waktu habis, order yang masih berjalan
+// dibatalkan dan browser-nya ditutup paksa (order tersebut nanti ditangani reaper).
+func (p *Pool) Shutdown(ctx context.Context) error {
+	log.Println("🛑 Menghentikan worker pool, menunggu order in-flight selesai...")
+
+	p.mu.Lock()
+	p.stop()
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("✅ Worker pool berhenti, semua order in-flight selesai")
+		p.abort()
+		return nil
+	case <-ctx.Done():
+		log.Println("⚠️ Batas waktu shutdown habis, order yang masih berjalan dibatalkan")
+		p.abort()
+		p.Drivers.Close()
+		return ctx.Err()
+	}
+}
+
+// sleepCtx menunggu selama d, return false jika ctx dibatalkan lebih dulu
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// promoteRetries memindahkan job retry yang sudah jatuh tempo ke antrian utama
+func (p *Pool) promoteRetries(ctx context.Context) {
+	for sleepCtx(ctx, retryPromoteInterval) {
+		for _, supplier := range p.activeSuppliers() {
+			n, err := p.Queue.PromoteDue(ctx, supplier.ID)
+			if err != nil {
+				log.Printf("⚠️ Gagal memindahkan job retry %s: %v", supplier.Code, err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("🔁 %d order %s masuk antrian untuk dicoba ulang", n, supplier.Code)
+			}
+		}
+	}
+}
+
+// activeSuppliers mengembalikan supplier yang sedang dilayani pool
+func (p *Pool) activeSuppliers() []*db.SupplierModel {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	suppliers := make([]*db.SupplierModel, 0, len(p.suppliers))
+	for _, sw := range p.suppliers {
+		if supplier := sw.supplier.Load(); supplier != nil && sw.size.Load() > 0 {
+			suppliers = append(suppliers, supplier)
+		}
+	}
+	return suppliers
+}
+
+// loadSuppliers mengambil semua supplier aktif yang punya driver
+func (p *Pool) loadSuppliers(ctx context.Context) ([]db.SupplierModel, error) {
+	suppliers, err := p.DB.Supplier.FindMany(
+		db.Supplier.Status.Equals(true),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	supported := suppliers[:0]
+	for i := range suppliers {
+		if !p.Drivers.Supports(&suppliers[i]) {
+			log.Printf("⚠️ Supplier %s (code: %s, type: %s) belum punya driver, order-nya tidak diproses",
+				suppliers[i].Name, suppliers[i].Code, suppliers[i].Type)
+			continue
+		}
+		supported = append(supported, suppliers[i])
+	}
+	return supported, nil
+}
+
+// refreshSuppliers menyesuaikan jumlah worker dengan konfigurasi supplier di DB
+func (p *Pool) refreshSuppliers(ctx context.Context) {
+	suppliers, err := p.loadSuppliers(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("❌ Worker Pool Error: gagal memuat supplier: %v", err)
+		return
+	}
+	if len(suppliers) == 0 {
+		log.Printf("❌ Worker Pool Error: tidak ada supplier aktif untuk diproses")
+	}
+
+	// Kapasitas akun = max_concurrency terkecil dari supplier yang memakai akun itu
+	capacities := make(map[string]int)
+	for i := range suppliers {
+		account := accountKey(&suppliers[i])
+		size := concurrencyOf(&suppliers[i])
+		if c, ok := capacities[account]; !ok || size < c {
+			capacities[account] = size
+		}
+	}
+
+	active := make(map[string]bool)
+
+	// Semaphore di-resize di tempat agar slot yang sedang dipegang tetap terhitung
+	p.mu.Lock()
+	for account, capacity := range capacities {
+		if sem, ok := p.semaphores[account]; ok {
+			sem.resize(capacity)
+		} else {
+			p.semaphores[account] = newAccountSemaphore(capacity)
+		}
+	}
+	p.mu.Unlock()
+
+	for i := range suppliers {
+		supplier := suppliers[i]
+		size := concurrencyOf(&supplier)
+		active[supplier.ID] = true
+
+		p.mu.Lock()
+		sw, exists := p.suppliers[supplier.ID]
+		if !exists {
+			sw = &supplierWorkers{slots: make(map[int]bool)}
+			p.suppliers[supplier.ID] = sw
+		}
+		p.mu.Unlock()
+
+		sw.supplier.Store(&supplier)
+		sw.size.Store(int32(size))
+
+		if !exists {
+			// Pulihkan job yang tertinggal di list processing sebelum worker jalan
+			recoverProcessing(ctx, p.DB, p.Queue, supplier.ID)
+		}
+		rescanPendingOrders(ctx, p.DB, p.Queue, supplier.ID)
+
+		p.spawnWorkers(sw, size)
+	}
+
+	// Supplier yang nonaktif/dihapus: hentikan worker-nya
+	p.mu.Lock()
+	for id, sw := range p.suppliers {
+		if !active[id] {
+			sw.size.Store(0)
+		}
+	}
+	p.mu.Unlock()
+}
+
+func concurrencyOf(supplier *db.SupplierModel) int {
+	if supplier.MaxConcurrency < 1 {
+		return 1
+	}
+	return supplier.MaxConcurrency
+}
+
+func (p *Pool) spawnWorkers(sw *supplierWorkers, size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Jangan menambah worker jika shutdown sudah dimulai
+	if p.stopCtx.Err() != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		if sw.slots[i] {
+			continue
+		}
+		sw.slots[i] = true
+		p.workers.Add(1)
+		go p.runWorker(sw, i)
+	}
+}
+
+func (p *Pool) runWorker(sw *supplierWorkers, index int) {
+	defer p.workers.Done()
+	defer func() {
+		p.mu.Lock()
+		delete(sw.slots, index)
+		p.mu.Unlock()
+	}()
+
+	for {
+		// Shutdown dimulai / pool mengecil (max_concurrency diturunkan / supplier nonaktif)
+		if p.stopCtx.Err() != nil || index >= int(sw.size.Load()) {
+			return
+		}
+
+		supplier := sw.supplier.Load()
+
+		// Supplier di-pause admin: job tetap di antrian sampai di-resume
+		if supplier.Paused {
+			if !sleepCtx(p.stopCtx, queueBlockTimeout) {
+				return
+			}
+			continue
+		}
+
+		sem := p.semaphore(supplier)
+
+		// Ambil slot akun dulu agar tidak mengambil job yang belum bisa dijalankan
+		if !sem.Acquire(p.stopCtx) {
+			return
+		}
+
+		orderID, err := p.Queue.Dequeue(p.stopCtx, supplier.ID, queueBlockTimeout)
+		if err != nil {
+			sem.Release()
+			if p.stopCtx.Err() != nil {
+				return
+			}
+			log.Printf("❌ Worker Error (dequeue %s): %v", supplier.Code, err)
+			sleepCtx(p.stopCtx, 5*time.Second)
+			continue
+		}
+		if orderID == "" {
+			sem.Release()
+			continue // Timeout, tidak ada antrian
+		}
+
+		// Job terambil tepat saat shutdown dimulai: kembalikan ke antrian
+		if p.stopCtx.Err() != nil {
+			sem.Release()
+			if err := p.Queue.Requeue(p.workCtx, supplier.ID, orderID); err != nil {
+				log.Printf("⚠️ Gagal mengembalikan job %s ke antrian: %v", orderID, err)
+			}
+			return
+		}
+
+		// Order in-flight memakai workCtx agar tetap selesai walau shutdown dimulai
+		sw.busy.Add(1)
+		if err := p.processSupplierOrder(p.workCtx, supplier, orderID); err != nil {
+			log.Printf("❌ Worker Error: %v", err)
+		}
+		sw.busy.Add(-1)
+		sem.Release()
+
+		if err := p.Queue.Ack(p.workCtx, supplier.ID, orderID); err != nil {
+			log.Printf("⚠️ Gagal ack job %s: %v", orderID, err)
+		}
+	}
+}
+
+// semaphore mengembalikan semaphore milik akun supplier
+func (p *Pool) semaphore(supplier *db.SupplierModel) *accountSemaphore {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	account := accountKey(supplier)
+	sem, ok := p.semaphores[account]
+	if !ok {
+		sem = newAccountSemaphore(1)
+		p.semaphores[account] = sem
+	}
+	return sem
+}
+
+// TryAcquireAccount mengambil slot akun supplier tanpa menunggu, dipakai
+// validasi destination agar berbagi batas max_concurrency dengan worker
+func (p *Pool) TryAcquireAccount(supplier *db.SupplierModel) (func(), bool) {
+	sem := p.semaphore(supplier)
+	if !sem.TryAcquire() {
+		return nil, false
+	}
+	return sem.Release, true
+}
+
+// accountKey: supplier dengan username yang sama memakai akun (dan sesi) yang sama
+func accountKey(supplier *db.SupplierModel) string {
+	if username, ok := supplier.Username(); ok && username != "" {
+		return supplier.Code + ":" + username
+	}
+	return supplier.ID
+}
+
+// Stats mengembalikan kondisi antrian dan worker untuk setiap supplier
+func (p *Pool) Stats(ctx context.Context) []SupplierStats {
+	p.mu.Lock()
+	type entry struct {
+		sw      *supplierWorkers
+		workers int
+	}
+	entries := make([]entry, 0, len(p.suppliers))
+	for _, sw := range p.suppliers {
+		entries = append(entries, entry{sw: sw, workers: len(sw.slots)})
+	}
+	p.mu.Unlock()
+
+	partial, err := p.partialCounts(ctx)
+	if err != nil {
+		log.Printf("⚠️ Gagal menghitung order partial: %v", err)
+	}
+
+	stats := make([]SupplierStats, 0, len(entries))
+	for _, e := range entries {
+		supplier := e.sw.supplier.Load()
+		if supplier == nil {
+			continue
+		}
+
+		depth, err := p.Queue.Depth(ctx, supplier.ID)
+		if err != nil {
+			log.Printf("⚠️ Gagal membaca queue depth %s: %v", supplier.Code, err)
+		}
+		delayed, err := p.Queue.Delayed(ctx, supplier.ID)
+		if err != nil {
+			log.Printf("⚠️ Gagal membaca jadwal retry %s: %v", supplier.Code, err)
+		}
+		processing, err := p.Queue.Processing(ctx, supplier.ID)
+		if err != nil {
+			log.Printf("⚠️ Gagal membaca list processing %s: %v", supplier.Code, err)
+		}
+
+		stats = append(stats, SupplierStats{
+			SupplierID:     supplier.ID,
+			SupplierCode:   supplier.Code,
+			SupplierName:   supplier.Name,
+			MaxConcurrency: int(e.sw.size.Load()),
+			Paused:         supplier.Paused,
+			Workers:        e.workers,
+			ActiveWorkers:  int(e.sw.busy.Load()),
+			QueueDepth:     depth,
+			RetryScheduled: delayed,
+			Processing:     len(processing),
+			Partial:        partial[supplier.ID],
+		})
+	}
+	return stats
+}
+
+// partialCounts menghitung order 'partial' per supplier dengan satu query COUNT
+func (p *Pool) partialCounts(ctx context.Context) (map[string]int, error) {
+	var rows []map[string]interface{}
+	err := p.DB.Prisma.QueryRaw(
+		"SELECT supplier_id, COUNT(*) AS total FROM supplier_order WHERE status = ? GROUP BY supplier_id",
+		orderstate.Partial,
+	).Exec(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if id, ok := row["supplier_id"].(string); ok {
+			counts[id] = rawInt(row["total"], 0)
+		}
+	}
+	return counts, nil
+}
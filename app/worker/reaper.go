@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+)
+
+var (
+	// Order 'processing' tanpa lease (sebelum ada klaim worker) yang tidak berubah
+	// lebih lama dari ini dianggap macet
+	processingTimeout = envDuration("WORKER_PROCESSING_TIMEOUT", 15*time.Minute)
+
+	// Interval reaper memeriksa order yang macet
+	reaperInterval = envDuration("WORKER_REAPER_INTERVAL", time.Minute)
+)
+
+// runReaper memeriksa secara berkala supplier order yang macet di 'processing'
+func (p *Pool) runReaper(ctx context.Context) {
+	for sleepCtx(ctx, reaperInterval) {
+		p.reapStuckOrders(ctx)
+	}
+}
+
+// reapStuckOrders mencari order 'processing' yang lease-nya habis (worker mati
+// atau berhenti memperpanjang lease). Order yang masih dipegang worker hidup
+// tidak disentuh walau prosesnya lama, karena lease terus diperpanjang.
+// Order yang belum sampai tahap pembelian dikembalikan ke antrian; yang sudah
+// mulai membeli ditandai 'manual_review' agar tidak terjadi pembelian dobel di supplier.
+func (p *Pool) reapStuckOrders(ctx context.Context) {
+	now := time.Now()
+
+	orders, err := p.DB.SupplierOrder.FindMany(
+		db.SupplierOrder.Status.Equals(orderstate.Processing),
+		db.SupplierOrder.Or(
+			db.SupplierOrder.LeaseExpiresAt.Lt(now),
+			// Order lama sebelum ada klaim worker (lease kosong)
+			db.SupplierOrder.And(
+				db.SupplierOrder.LeaseExpiresAt.IsNull(),
+				db.SupplierOrder.UpdatedAt.Lt(now.Add(-processingTimeout)),
+			),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		log.Printf("⚠️ Reaper gagal membaca order macet: %v", err)
+		return
+	}
+
+	for i := range orders {
+		p.reapOrder(ctx, &orders[i])
+	}
+}
+
+func (p *Pool) reapOrder(ctx context.Context, order *db.SupplierOrderModel) {
+	stage, _ := order.Stage()
+	holder, _ := order.WorkerID()
+	claimedAt, ok := order.ClaimedAt()
+	if !ok {
+		claimedAt = order.UpdatedAt
+	}
+
+	safeToRequeue := (stage == stageClaimed || stage == stageLogin) && order.Attempt < maxAttempts
+
+	action := "manual_review"
+	if safeToRequeue {
+		action = "requeue"
+	}
+	reason := fmt.Sprintf("[REAPER] macet di 'processing' sejak %s (stage: %s, worker: %s) -> %s",
+		claimedAt.Format("02 Jan 2006 15:04:05"), stage, holder, action)
+
+	to := orderstate.ManualReview
+	params := []db.SupplierOrderSetParam{db.SupplierOrder.LastError.Set(reason)}
+	if safeToRequeue {
+		to = orderstate.Pending
+		params = append(params, db.SupplierOrder.NextAttemptAt.Set(time.Now()))
+	}
+
+	// Conditional update: reaper lain / worker asli tidak bisa ikut menimpa
+	var where []db.SupplierOrderWhereParam
+	if holder != "" {
+		where = append(where, db.SupplierOrder.WorkerID.Equals(holder))
+	} else {
+		where = append(where, db.SupplierOrder.WorkerID.IsNull())
+	}
+
+	ok, err := p.States.SupplierOrder(ctx, orderstate.SupplierChange{
+		OrderID: order.ID,
+		From:    orderstate.Processing,
+		To:      to,
+		Reason:  reason,
+		Actor:   "system:reaper",
+		Where:   where,
+		Set:     params,
+	})
+
+	if err != nil {
+		log.Printf("⚠️ Reaper gagal update order %s: %v", order.ID, err)
+		return
+	}
+	if !ok {
+		return // Sudah selesai / diambil reaper lain
+	}
+
+	log.Printf("🧹 %s", reason)
+
+	// Internal order mengikuti; seller hanya dinotifikasi jika order perlu dicek manual
+	p.setInternalStatus(ctx, order.InternalOrderID, to, reason, "system:reaper")
+	if to == orderstate.ManualReview {
+		p.NotifyOrderStatus(ctx, order.InternalOrderID, to, "", false)
+	}
+
+	// Bersihkan sisa job di list processing, lalu masukkan ulang jika aman
+	p.Queue.Ack(ctx, order.SupplierID, order.ID)
+	if safeToRequeue {
+		if err := p.Queue.Enqueue(ctx, order.SupplierID, order.ID); err != nil {
+			log.Printf("⚠️ Reaper gagal enqueue order %s: %v", order.ID, err)
+		}
+	}
+
+	if p.Telegram.HasAdmin() {
+		msg := fmt.Sprintf(`
+<b>🧹 ORDER MACET DITANGANI REAPER</b>
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬
+<b>ID Order:</b> <code>%s</code>
+<b>Internal ID:</b> %s
+<b>Tindakan:</b> <pre>%s</pre>
+<b>Detail:</b> %s
+▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬▬`, html.EscapeString(order.ID), html.EscapeString(order.InternalOrderID), action, html.EscapeString(reason))
+
+		p.Telegram.NotifyAdmin(msg)
+	}
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/prisma/db"
+)
+
+var (
+	// Lama worker menunggu (blocking) job baru sebelum loop ulang
+	queueBlockTimeout = envDuration("WORKER_QUEUE_BLOCK_TIMEOUT", 5*time.Second)
+
+	// Interval fallback scan DB (dan refresh daftar supplier di Pool)
+	pendingRescanInterval = envDuration("WORKER_RESCAN_INTERVAL", time.Minute)
+
+	// Order pending yang lebih muda dari ini dianggap masih dalam proses enqueue
+	pendingRescanGrace = 30 * time.Second
+)
+
+// recoverProcessing dipanggil saat supplier pertama kali dilayani pool untuk
+// membereskan job yang sudah diambil dari antrian tapi belum di-Ack (misal
+// proses mati / restart). List processing dipakai bersama semua replica, jadi
+// job yang mungkin masih dipegang worker lain tidak disentuh:
+//   - processing dengan lease masih berlaku: sedang dikerjakan, dibiarkan
+//   - processing dengan lease habis: urusan reaper (reaper juga yang Ack)
+//   - pending yang baru saja berubah: mungkin baru di-dequeue replica lain, dibiarkan
+//   - pending lainnya dikembalikan ke antrian, status lain (sudah selesai) di-Ack
+func recoverProcessing(ctx context.Context, dbClient *db.PrismaClient, queue *services.OrderQueue, supplierID string) {
+	orderIDs, err := queue.Processing(ctx, supplierID)
+	if err != nil {
+		log.Printf("⚠️ Gagal membaca list processing: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, orderID := range orderIDs {
+		order, err := dbClient.SupplierOrder.FindUnique(
+			db.SupplierOrder.ID.Equals(orderID),
+		).Exec(ctx)
+
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				queue.Ack(ctx, supplierID, orderID)
+				continue
+			}
+			log.Printf("⚠️ Gagal membaca order %s saat recovery: %v", orderID, err)
+			continue
+		}
+
+		switch order.Status {
+		case orderstate.Processing:
+			continue
+
+		case orderstate.Pending:
+			// Dequeue -> klaim hanya butuh sesaat; job yang masih "segar" bisa jadi
+			// sedang diklaim replica lain. Klaim yang atomik mencegah proses dobel,
+			// tapi job tetap tidak diduplikasi tanpa perlu.
+			if order.UpdatedAt.After(now.Add(-pendingRescanGrace)) {
+				continue
+			}
+			if lease, ok := order.LeaseExpiresAt(); ok && lease.After(now) {
+				continue
+			}
+			if err := queue.Requeue(ctx, supplierID, orderID); err != nil {
+				log.Printf("⚠️ Gagal requeue job %s: %v", orderID, err)
+				continue
+			}
+			log.Printf("♻️ Job %s dikembalikan ke antrian", orderID)
+
+		default:
+			queue.Ack(ctx, supplierID, orderID)
+		}
+	}
+}
+
+// rescanPendingOrders memindai DB dan memasukkan ulang order 'pending' yang
+// tidak ada di antrian (misal Redis sempat down saat enqueue).
+// Dipanggil berkala oleh Pool.
+func rescanPendingOrders(ctx context.Context, dbClient *db.PrismaClient, queue *services.OrderQueue, supplierID string) {
+	orders, err := dbClient.SupplierOrder.FindMany(
+		db.SupplierOrder.Status.Equals("pending"),
+		db.SupplierOrder.SupplierID.Equals(supplierID),
+		db.SupplierOrder.CreatedAt.Before(time.Now().Add(-pendingRescanGrace)),
+	).Exec(ctx)
+
+	if err != nil {
+		log.Printf("⚠️ Rescan pending order gagal: %v", err)
+		return
+	}
+
+	for _, order := range orders {
+		// Order yang menunggu jadwal retry dikembalikan ke antrian terjadwal
+		if nextAttempt, ok := order.NextAttemptAt(); ok && nextAttempt.After(time.Now()) {
+			if err := queue.EnqueueAt(ctx, supplierID, order.ID, nextAttempt); err != nil {
+				log.Printf("⚠️ Gagal menjadwalkan ulang order %s: %v", order.ID, err)
+			}
+			continue
+		}
+
+		added, err := queue.EnqueueIfAbsent(ctx, supplierID, order.ID)
+		if err != nil {
+			log.Printf("⚠️ Gagal enqueue ulang order %s: %v", order.ID, err)
+			continue
+		}
+		if added {
+			log.Printf("♻️ Order yatim #%s dimasukkan ulang ke antrian", order.ID)
+		}
+	}
+}
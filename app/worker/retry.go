@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+var (
+	// Maksimal percobaan per supplier order (termasuk percobaan pertama)
+	maxAttempts = envInt("WORKER_MAX_ATTEMPTS", 3)
+
+	// Delay retry pertama, berlipat dua tiap percobaan sampai retryMaxDelay
+	retryBaseDelay = envDuration("WORKER_RETRY_BASE_DELAY", 30*time.Second)
+	retryMaxDelay  = envDuration("WORKER_RETRY_MAX_DELAY", 15*time.Minute)
+
+	// Interval pemindahan job retry yang sudah jatuh tempo ke antrian utama
+	retryPromoteInterval = 5 * time.Second
+)
+
+// permanentError menandai error yang tidak akan berhasil walau diulang
+// (misal ID player salah atau kredensial supplier kosong)
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// retryDelay menghitung exponential backoff dengan jitter untuk attempt ke-n.
+// Setengah delay bersifat tetap, setengahnya acak agar retry tidak serentak.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	// Default: base 30 detik, maks 15 menit; hasil = setengah tetap + setengah acak
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 15 * time.Second, 30 * time.Second},
+		{2, 30 * time.Second, time.Minute},
+		{3, time.Minute, 2 * time.Minute},
+		{5, 4 * time.Minute, 8 * time.Minute},
+		{6, 7*time.Minute + 30*time.Second, 15 * time.Minute}, // 16 menit dibatasi 15 menit
+		{50, 7*time.Minute + 30*time.Second, 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			got := retryDelay(tt.attempt)
+			if got < tt.min || got > tt.max {
+				t.Fatalf("retryDelay(%d) = %s, harus di antara %s dan %s", tt.attempt, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	cause := errors.New("ID player salah")
+	if !isPermanent(permanent(cause)) || isPermanent(cause) {
+		t.Fatal("isPermanent harus hanya true untuk error yang dibungkus permanent()")
+	}
+}
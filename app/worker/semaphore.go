@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// accountSemaphore membatasi jumlah sesi paralel satu akun supplier.
+// Kapasitas bisa diubah di tempat (resize) tanpa mengganti semaphore, sehingga
+// slot yang sedang dipegang tetap terhitung dan batas tidak pernah terlampaui
+// saat max_concurrency diubah.
+type accountSemaphore struct {
+	mu       sync.Mutex
+	capacity int
+	inUse    int
+	changed  chan struct{} // Ditutup (lalu diganti) setiap ada slot lepas / kapasitas berubah
+}
+
+func newAccountSemaphore(capacity int) *accountSemaphore {
+	return &accountSemaphore{capacity: capacity, changed: make(chan struct{})}
+}
+
+// Acquire menunggu sampai ada slot kosong. Return false jika ctx dibatalkan lebih dulu.
+func (s *accountSemaphore) Acquire(ctx context.Context) bool {
+	for {
+		s.mu.Lock()
+		if s.inUse < s.capacity {
+			s.inUse++
+			s.mu.Unlock()
+			return true
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// TryAcquire mengambil slot tanpa menunggu
+func (s *accountSemaphore) TryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inUse >= s.capacity {
+		return false
+	}
+	s.inUse++
+	return true
+}
+
+// Release mengembalikan slot yang diambil lewat Acquire / TryAcquire
+func (s *accountSemaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inUse--
+	s.notify()
+}
+
+// resize mengubah kapasitas. Jika kapasitas turun, slot yang sedang dipegang
+// tetap jalan sampai dilepas, tapi slot baru baru diberikan setelah inUse < capacity.
+func (s *accountSemaphore) resize(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.capacity == capacity {
+		return
+	}
+	s.capacity = capacity
+	s.notify()
+}
+
+// notify membangunkan semua yang sedang menunggu (dipanggil dengan s.mu terkunci)
+func (s *accountSemaphore) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
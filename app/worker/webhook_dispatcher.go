@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+var (
+	// Interval dispatcher memeriksa webhook yang jadwal kirim ulangnya sudah tiba
+	webhookDispatchInterval = envDuration("WEBHOOK_DISPATCH_INTERVAL", 5*time.Second)
+
+	// Maksimal webhook yang dicoba per putaran
+	webhookDispatchBatch = envInt("WEBHOOK_DISPATCH_BATCH", 50)
+)
+
+// webhookEvent adalah message_type webhook perubahan transaksi
+const webhookEvent = "transaction_update"
+
+// queueWebhook menyimpan webhook seller ke outbox. Percobaan pertama langsung
+// dijalankan; jika gagal, dispatcher mengirim ulang sesuai jadwal.
+func (p *Pool) queueWebhook(ctx context.Context, userID, targetURL, orderID string, payload interface{}) {
+	if _, err := p.Webhooks.Enqueue(ctx, userID, targetURL, webhookEvent, orderID, payload); err != nil {
+		log.Printf("❌ Gagal menyimpan webhook order %s ke outbox: %v", orderID, err)
+	}
+}
+
+// runWebhookDispatcher mengirim ulang webhook yang gagal sampai berhasil atau jadwal habis
+func (p *Pool) runWebhookDispatcher(ctx context.Context) {
+	for sleepCtx(ctx, webhookDispatchInterval) {
+		if _, err := p.Webhooks.DispatchDue(ctx, webhookDispatchBatch); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️ Webhook dispatcher error: %v", err)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"gerbangapi/app/handlers"
 	"gerbangapi/app/routes"
 	"gerbangapi/app/services"
+	"gerbangapi/app/services/driver"
 	"gerbangapi/app/worker"
 	"gerbangapi/prisma/db"
 
@@ -31,12 +37,23 @@ func main() {
 	}
 	serverAddress := fmt.Sprintf(":%s", port)
 
+	// Batas waktu menunggu order in-flight selesai saat shutdown (SIGTERM)
+	shutdownTimeout := 2 * time.Minute
+	if val := os.Getenv("SHUTDOWN_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			shutdownTimeout = d
+		}
+	}
+
+	// Context yang dibatalkan saat menerima SIGINT / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 2. Setup DB Connections (Prisma)
 	client := db.NewClient()
 	if err := client.Prisma.Connect(); err != nil {
 		log.Fatal("❌ Prisma failed to connect:", err)
 	}
-	defer client.Prisma.Disconnect()
 
 	// 3. Setup Redis Connection
 	redisAddr := os.Getenv("REDIS_ADDR")
@@ -63,8 +80,10 @@ func main() {
 	// ---------------------------------------------------------
 	// [2] START WORKER (BACKGROUND)
 	// ---------------------------------------------------------
-	// Worker berjalan otomatis di goroutine terpisah untuk memantau order
-	worker.StartWorker(client, redisClient)
+	// Worker berjalan otomatis di goroutine terpisah dan mendengarkan antrian Redis
+	orderQueue := services.NewOrderQueue(redisClient)
+	supplierDrivers := driver.NewManager(redisClient) // Dipakai bersama worker & validasi destination
+	workerPool := worker.StartWorker(client, redisClient, orderQueue, supplierDrivers)
 
 	// 4. Create Echo Instance & Global Middleware
 	e := echo.New()
@@ -79,14 +98,26 @@ func main() {
 
 	// A. Services
 	authService := services.NewAuthService(client, redisClient)
-	orderService := services.NewOrderService(client)
+	orderService := services.NewOrderService(client, orderQueue)
+	destinationService := services.NewDestinationService(client, redisClient, supplierDrivers, workerPool)
 
 	// B. Handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	sellerHandler := handlers.NewSellerHandler(client, orderService, redisClient)
+	sellerHandler := handlers.NewSellerHandler(client, orderService, destinationService, redisClient, workerPool.Webhooks)
 	
-	// [BARU] Inisialisasi Telegram Handler untuk Deep Linking
-	telegramHandler := handlers.NewTelegramHandler(client) 
+	// Telegram Handler (deep linking & command bot), memakai client Telegram yang sama dengan worker
+	telegramHandler := handlers.NewTelegramHandler(client, redisClient, workerPool.Telegram)
+
+	// Daftarkan webhook bot (dengan secret_token) jika TELEGRAM_WEBHOOK_URL diisi
+	if webhookURL := os.Getenv("TELEGRAM_WEBHOOK_URL"); webhookURL != "" {
+		if err := workerPool.Telegram.SetWebhook(context.Background(), webhookURL); err != nil {
+			log.Printf("⚠️ Gagal mendaftarkan webhook Telegram: %v", err)
+		} else {
+			log.Println("✅ Webhook Telegram terdaftar")
+		}
+	} else if workerPool.Telegram.WebhookSecret == "" {
+		log.Println("⚠️ TELEGRAM_WEBHOOK_SECRET kosong, webhook Telegram akan menolak semua update")
+	}
 
 	// CRUD Handlers
 	supplierHandler := handlers.NewSupplierHandler(client, redisClient)
@@ -97,6 +128,9 @@ func main() {
 	// payment type
 	paymentTypeHandler := handlers.NewPaymentTypeHandler(client, redisClient)
 
+	// admin (monitoring worker & antrian)
+	adminHandler := handlers.NewAdminHandler(client, workerPool)
+
 	// ---------------------------------------------------------
 	// 6. REGISTER ROUTES
 	// ---------------------------------------------------------
@@ -111,9 +145,44 @@ func main() {
 		recipeHandler,
 		telegramHandler,
 		paymentTypeHandler,
+		adminHandler,
 	)
 
 	// 7. Start Server
-	log.Printf("🚀 Server running on http://localhost%s", serverAddress)
-	e.Logger.Fatal(e.Start(serverAddress))
+	go func() {
+		log.Printf("🚀 Server running on http://localhost%s", serverAddress)
+		if err := e.Start(serverAddress); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// 8. Graceful Shutdown
+	<-ctx.Done()
+	log.Printf("🛑 Sinyal shutdown diterima, batas waktu %s", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// A. Stop menerima request HTTP baru (request yang berjalan ditunggu)
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ HTTP server shutdown: %v", err)
+	}
+
+	// B. Stop klaim order baru & tunggu order in-flight (browser ditutup di sini)
+	if err := workerPool.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Worker shutdown: %v", err)
+	}
+
+	// C. Tutup sesi browser supplier
+	supplierDrivers.Close()
+
+	// D. Tutup koneksi Redis & Prisma
+	if err := redisClient.Close(); err != nil {
+		log.Printf("⚠️ Redis close: %v", err)
+	}
+	if err := client.Prisma.Disconnect(); err != nil {
+		log.Printf("⚠️ Prisma disconnect: %v", err)
+	}
+
+	log.Println("👋 Server stopped")
 }
\ No newline at end of file
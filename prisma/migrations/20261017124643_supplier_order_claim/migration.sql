@@ -0,0 +1,4 @@
+-- AlterTable
+ALTER TABLE `supplier_order` ADD COLUMN `claimed_at` DATETIME(3) NULL,
+    ADD COLUMN `lease_expires_at` DATETIME(3) NULL,
+    ADD COLUMN `worker_id` VARCHAR(191) NULL;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE `supplier` ADD COLUMN `max_concurrency` INTEGER NOT NULL DEFAULT 1;
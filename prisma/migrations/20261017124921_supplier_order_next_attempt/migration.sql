@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE `supplier_order` ADD COLUMN `next_attempt_at` DATETIME(3) NULL;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE `supplier` ADD COLUMN `execution_mode` VARCHAR(191) NOT NULL DEFAULT 'browser';
@@ -0,0 +1,3 @@
+-- AlterTable
+ALTER TABLE `supplier_order_item` ADD COLUMN `completed_qty` INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN `payment_urls` TEXT NULL;
@@ -0,0 +1,39 @@
+-- CreateTable
+CREATE TABLE `supplier_transaction` (
+    `id` VARCHAR(191) NOT NULL,
+    `supplier_order_item_id` VARCHAR(191) NOT NULL,
+    `sequence` INTEGER NOT NULL,
+    `payment_url` TEXT NULL,
+    `supplier_ref` VARCHAR(191) NULL,
+    `status` VARCHAR(191) NOT NULL DEFAULT 'success',
+    `created_at` DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    `updated_at` DATETIME(3) NOT NULL,
+
+    UNIQUE INDEX `supplier_transaction_supplier_order_item_id_sequence_key`(`supplier_order_item_id`, `sequence`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `supplier_transaction` ADD CONSTRAINT `supplier_transaction_supplier_order_item_id_fkey` FOREIGN KEY (`supplier_order_item_id`) REFERENCES `supplier_order_item`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- Backfill: pindahkan URL unit yang sudah terbeli (payment_urls, dipisah koma) ke ledger
+INSERT INTO `supplier_transaction` (`id`, `supplier_order_item_id`, `sequence`, `payment_url`, `status`, `created_at`, `updated_at`)
+WITH RECURSIVE `split` (`item_id`, `seq`, `url`, `rest`) AS (
+    SELECT `id`, 1,
+        TRIM(SUBSTRING_INDEX(`payment_urls`, ',', 1)),
+        IF(LOCATE(',', `payment_urls`) > 0, SUBSTRING(`payment_urls`, LOCATE(',', `payment_urls`) + 1), NULL)
+    FROM `supplier_order_item`
+    WHERE `payment_urls` IS NOT NULL AND `payment_urls` <> ''
+    UNION ALL
+    SELECT `item_id`, `seq` + 1,
+        TRIM(SUBSTRING_INDEX(`rest`, ',', 1)),
+        IF(LOCATE(',', `rest`) > 0, SUBSTRING(`rest`, LOCATE(',', `rest`) + 1), NULL)
+    FROM `split`
+    WHERE `rest` IS NOT NULL
+)
+SELECT UUID(), `item_id`, `seq`, `url`, 'success', CURRENT_TIMESTAMP(3), CURRENT_TIMESTAMP(3)
+FROM `split`
+WHERE `url` <> '';
+
+-- AlterTable
+ALTER TABLE `supplier_order_item` DROP COLUMN `payment_urls`;
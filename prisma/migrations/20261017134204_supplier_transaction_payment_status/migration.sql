@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE `supplier_transaction` ALTER COLUMN `status` SET DEFAULT 'awaiting_payment';
+
+-- Unit lama berstatus 'success' belum pernah dicek pembayarannya; masukkan ke poller
+UPDATE `supplier_transaction` SET `status` = 'awaiting_payment' WHERE `status` = 'success';
@@ -0,0 +1,14 @@
+-- CreateTable
+CREATE TABLE `order_status_history` (
+    `id` VARCHAR(191) NOT NULL,
+    `order_type` VARCHAR(191) NOT NULL,
+    `order_id` VARCHAR(191) NOT NULL,
+    `from_status` VARCHAR(191) NULL,
+    `to_status` VARCHAR(191) NOT NULL,
+    `reason` TEXT NULL,
+    `actor` VARCHAR(191) NOT NULL,
+    `created_at` DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+
+    INDEX `order_status_history_order_type_order_id_idx`(`order_type`, `order_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE `internal_order` ADD COLUMN `payment_expires_at` DATETIME(3) NULL;
+
+-- AlterTable
+ALTER TABLE `payment_type` ADD COLUMN `payment_ttl_minutes` INTEGER NOT NULL DEFAULT 60;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE `supplier_order` ADD COLUMN `artifact_path` VARCHAR(191) NULL;
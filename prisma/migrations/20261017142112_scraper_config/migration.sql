@@ -0,0 +1,19 @@
+-- AlterTable
+ALTER TABLE `supplier` ADD COLUMN `scraper_config` TEXT NULL,
+    ADD COLUMN `scraper_config_version` INTEGER NOT NULL DEFAULT 0;
+
+-- CreateTable
+CREATE TABLE `scraper_config_version` (
+    `id` VARCHAR(191) NOT NULL,
+    `supplier_id` VARCHAR(191) NOT NULL,
+    `version` INTEGER NOT NULL,
+    `config` TEXT NOT NULL,
+    `actor` VARCHAR(191) NOT NULL,
+    `created_at` DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+
+    UNIQUE INDEX `scraper_config_version_supplier_id_version_key`(`supplier_id`, `version`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `scraper_config_version` ADD CONSTRAINT `scraper_config_version_supplier_id_fkey` FOREIGN KEY (`supplier_id`) REFERENCES `supplier`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
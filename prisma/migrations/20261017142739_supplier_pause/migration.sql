@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE `supplier` ADD COLUMN `paused` BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN `paused_at` DATETIME(3) NULL,
+    ADD COLUMN `paused_by` VARCHAR(191) NULL,
+    ADD COLUMN `paused_reason` VARCHAR(191) NULL;
@@ -0,0 +1,3 @@
+-- AlterTable
+ALTER TABLE `api_key` ADD COLUMN `previous_secret` VARCHAR(191) NULL,
+    ADD COLUMN `secret_rotated_at` DATETIME(3) NULL;
@@ -0,0 +1,44 @@
+-- CreateTable
+CREATE TABLE `webhook_delivery` (
+    `id` VARCHAR(191) NOT NULL,
+    `user_id` VARCHAR(191) NOT NULL,
+    `event` VARCHAR(191) NOT NULL,
+    `order_id` VARCHAR(191) NULL,
+    `url` TEXT NOT NULL,
+    `payload` LONGTEXT NOT NULL,
+    `status` VARCHAR(191) NOT NULL DEFAULT 'pending',
+    `attempts` INTEGER NOT NULL DEFAULT 0,
+    `next_attempt_at` DATETIME(3) NULL,
+    `locked_until` DATETIME(3) NULL,
+    `last_status_code` INTEGER NULL,
+    `last_error` TEXT NULL,
+    `delivered_at` DATETIME(3) NULL,
+    `created_at` DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    `updated_at` DATETIME(3) NOT NULL,
+
+    INDEX `webhook_delivery_status_next_attempt_at_idx`(`status`, `next_attempt_at`),
+    INDEX `webhook_delivery_user_id_created_at_idx`(`user_id`, `created_at`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `webhook_attempt` (
+    `id` VARCHAR(191) NOT NULL,
+    `delivery_id` VARCHAR(191) NOT NULL,
+    `attempt` INTEGER NOT NULL,
+    `status_code` INTEGER NULL,
+    `latency_ms` INTEGER NOT NULL,
+    `response_body` TEXT NULL,
+    `error` TEXT NULL,
+    `actor` VARCHAR(191) NOT NULL,
+    `created_at` DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+
+    INDEX `webhook_attempt_delivery_id_idx`(`delivery_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `webhook_delivery` ADD CONSTRAINT `webhook_delivery_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `user`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `webhook_attempt` ADD CONSTRAINT `webhook_attempt_delivery_id_fkey` FOREIGN KEY (`delivery_id`) REFERENCES `webhook_delivery`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE `internal_order` ADD COLUMN `webhook_url` TEXT NULL;
@@ -0,0 +1,58 @@
+-- Data migration: order legacy berstatus 'success' (sebelum ada tracking pembayaran)
+-- dimasukkan ke alur pembayaran agar dicek poller lalu berakhir delivered / expired.
+
+-- 1. Backfill ledger dari provider_trx_id (URL dipisah koma) untuk order yang belum
+--    punya SupplierTransaction; unit ditautkan ke item pertama order
+INSERT INTO `supplier_transaction` (`id`, `supplier_order_item_id`, `sequence`, `payment_url`, `status`, `created_at`, `updated_at`)
+WITH RECURSIVE `first_item` AS (
+    SELECT `soi`.`id` AS `item_id`, `so`.`provider_trx_id` AS `urls`,
+        ROW_NUMBER() OVER (PARTITION BY `so`.`id` ORDER BY `soi`.`created_at`, `soi`.`id`) AS `rn`
+    FROM `supplier_order` `so`
+    JOIN `supplier_order_item` `soi` ON `soi`.`supplier_order_id` = `so`.`id`
+    WHERE `so`.`status` = 'success'
+        AND `so`.`provider_trx_id` IS NOT NULL AND `so`.`provider_trx_id` <> ''
+        AND NOT EXISTS (
+            SELECT 1 FROM `supplier_transaction` `st`
+            JOIN `supplier_order_item` `i` ON `i`.`id` = `st`.`supplier_order_item_id`
+            WHERE `i`.`supplier_order_id` = `so`.`id`
+        )
+),
+`split` (`item_id`, `seq`, `url`, `rest`) AS (
+    SELECT `item_id`, 1,
+        TRIM(SUBSTRING_INDEX(`urls`, ',', 1)),
+        IF(LOCATE(',', `urls`) > 0, SUBSTRING(`urls`, LOCATE(',', `urls`) + 1), NULL)
+    FROM `first_item`
+    WHERE `rn` = 1
+    UNION ALL
+    SELECT `item_id`, `seq` + 1,
+        TRIM(SUBSTRING_INDEX(`rest`, ',', 1)),
+        IF(LOCATE(',', `rest`) > 0, SUBSTRING(`rest`, LOCATE(',', `rest`) + 1), NULL)
+    FROM `split`
+    WHERE `rest` IS NOT NULL
+)
+SELECT UUID(), `item_id`, `seq`, `url`, 'awaiting_payment', CURRENT_TIMESTAMP(3), CURRENT_TIMESTAMP(3)
+FROM `split`
+WHERE `url` <> '';
+
+-- 2. Catat riwayat status sebelum status diubah
+INSERT INTO `order_status_history` (`id`, `order_type`, `order_id`, `from_status`, `to_status`, `reason`, `actor`, `created_at`)
+SELECT UUID(), 'supplier_order', `id`, 'success', 'awaiting_payment', 'migrasi order legacy ke tracking pembayaran', 'system:migration', CURRENT_TIMESTAMP(3)
+FROM `supplier_order` WHERE `status` = 'success';
+
+INSERT INTO `order_status_history` (`id`, `order_type`, `order_id`, `from_status`, `to_status`, `reason`, `actor`, `created_at`)
+SELECT UUID(), 'internal_order', `id`, 'success', 'awaiting_payment', 'migrasi order legacy ke tracking pembayaran', 'system:migration', CURRENT_TIMESTAMP(3)
+FROM `internal_order` WHERE `status` = 'success';
+
+-- 3. Pindahkan ke awaiting_payment
+UPDATE `supplier_order` SET `status` = 'awaiting_payment' WHERE `status` = 'success';
+
+-- Batas bayar minimal satu TTL dari sekarang, agar poller sempat mengecek unit
+-- yang sebenarnya sudah dibayar sebelum job expiry berjalan
+UPDATE `internal_order` `io`
+LEFT JOIN `payment_type` `pt` ON `pt`.`id` = `io`.`payment_type_id`
+SET `io`.`status` = 'awaiting_payment',
+    `io`.`payment_expires_at` = GREATEST(
+        `io`.`created_at` + INTERVAL COALESCE(`pt`.`payment_ttl_minutes`, 60) MINUTE,
+        CURRENT_TIMESTAMP(3) + INTERVAL COALESCE(`pt`.`payment_ttl_minutes`, 60) MINUTE
+    )
+WHERE `io`.`status` = 'success';
@@ -34,6 +34,10 @@ func main() {
 	log.Println("🧹 Membersihkan data lama...")
 
 	// Hapus Child dulu (Foreign Key constraints)
+	client.OrderStatusHistory.FindMany().Delete().Exec(ctx)
+	client.WebhookAttempt.FindMany().Delete().Exec(ctx)
+	client.WebhookDelivery.FindMany().Delete().Exec(ctx)
+	client.SupplierTransaction.FindMany().Delete().Exec(ctx)
 	client.SupplierOrderItem.FindMany().Delete().Exec(ctx)
 	client.SupplierOrder.FindMany().Delete().Exec(ctx)
 	client.InternalOrder.FindMany().Delete().Exec(ctx)
@@ -48,6 +52,7 @@ func main() {
 	// ==============================================================
 
 	// Baru hapus Parent
+	client.ScraperConfigVersion.FindMany().Delete().Exec(ctx)
 	client.Supplier.FindMany().Delete().Exec(ctx)
 	client.APIKey.FindMany().Delete().Exec(ctx)
 	client.RefreshToken.FindMany().Delete().Exec(ctx)
@@ -0,0 +1,527 @@
+//go:build e2e
+
+// Tes end-to-end scraper MitraHiggs (mode browser & http) secara headless
+// melawan fake offline (testing/fakemitrahiggs), termasuk skenario kegagalan
+// yang disuntikkan. Tidak butuh kredensial / akses ke situs asli, tapi butuh
+// Redis dan browser Playwright, sehingga hanya jalan dengan build tag e2e:
+//
+//	go test -tags e2e ./testing/e2e_mitrahiggs/                        # skenario driver (butuh Redis)
+//	go test -tags e2e ./testing/e2e_mitrahiggs/ -run TestDriver/popup  # hanya skenario "popup"
+//
+// TestWorker (jalur worker penuh) hanya jalan jika DATABASE_URL diisi. Tes ini
+// membuat supplier, produk & order di database lalu menjalankan worker pool
+// seperti di server. Pakai database khusus tes: worker juga akan melayani
+// supplier aktif lain yang ada di database tersebut. Set PAYMENT_POLL_INTERVAL=5s
+// agar tahap pembayaran tidak menunggu lama.
+package e2emitrahiggs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gerbangapi/app/services"
+	"gerbangapi/app/services/driver"
+	"gerbangapi/app/services/orderstate"
+	"gerbangapi/app/services/scraper"
+	"gerbangapi/app/worker"
+	"gerbangapi/prisma/db"
+	"gerbangapi/testing/fakemitrahiggs"
+
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	validPlayer   = "123456"
+	invalidPlayer = "000000"
+	productID     = "6"
+	paymentCode   = "40"
+)
+
+// env adalah fake + driver baru untuk satu skenario (sesi browser tidak dibagi antar skenario)
+type env struct {
+	t        *testing.T
+	fake     *fakemitrahiggs.Server
+	drivers  *driver.Manager
+	supplier *db.SupplierModel
+}
+
+type scenario struct {
+	name string
+	mode string
+	run  func(ctx context.Context, e *env) error
+}
+
+var scenarios = []scenario{
+	{"order_sukses", driver.ModeBrowser, scenarioOrderSuccess},
+	{"player_tidak_valid", driver.ModeBrowser, scenarioInvalidPlayer},
+	{"login_ditolak", driver.ModeBrowser, scenarioLoginRejected},
+	{"produk_tidak_ditemukan", driver.ModeBrowser, scenarioProductMissing},
+	{"popup_pembayaran_gagal", driver.ModeBrowser, scenarioPopupBlocked},
+	{"sesi_expired", driver.ModeBrowser, scenarioSessionExpired},
+	{"http_sellcard", driver.ModeHTTP, scenarioHTTPSellCard},
+}
+
+// Timeout pendek agar skenario gagal (login ditolak, dll) cepat selesai
+var scraperConfig = `{"timeouts": {"navigation": 10000, "login": 3000, "session_check": 5000, "payment_page": 5000, "payment_redirect": 3000}}`
+
+func TestMain(m *testing.M) {
+	// Tes berjalan dari direktori paket; .env ada di root repo
+	godotenv.Load("../../.env")
+
+	// Artefak kegagalan skenario disimpan terpisah dari artefak server
+	if os.Getenv("SCRAPER_ARTIFACT_DIR") == "" {
+		os.Setenv("SCRAPER_ARTIFACT_DIR", "../../storage/e2e-artifacts")
+	}
+	os.Exit(m.Run())
+}
+
+// newRedis menghubungkan Redis; tes dilewati jika Redis tidak bisa dihubungi
+func newRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       0,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		t.Skipf("Redis tidak bisa dihubungi (%s): %v", redisAddr, err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestDriver(t *testing.T) {
+	redisClient := newRedis(t)
+
+	for i, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			runScenario(t, redisClient, i, sc)
+		})
+	}
+}
+
+func runScenario(t *testing.T, redisClient *redis.Client, index int, sc scenario) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	username := fmt.Sprintf("e2e-%d-%d", time.Now().Unix(), index)
+	fake := fakemitrahiggs.New(fakemitrahiggs.Options{Username: username, Password: "rahasia"})
+	defer fake.Close()
+
+	drivers := driver.NewManager(redisClient)
+	defer drivers.Close()
+	defer redisClient.Del(context.Background(), "mitrahiggs:cookies:"+username)
+
+	t.Logf("%s (%s, fake: %s)", sc.name, sc.mode, fake.URL)
+
+	supplier := fakeSupplier(fmt.Sprintf("e2e-%d", index), username, fake.URL, sc.mode)
+	ctx = driver.WithArtifactKey(ctx, supplier.ID)
+
+	if err := sc.run(ctx, &env{t: t, fake: fake, drivers: drivers, supplier: supplier}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeSupplier membuat data supplier di memori (tanpa database)
+func fakeSupplier(id, username, baseURL, mode string) *db.SupplierModel {
+	password := "rahasia"
+	config := scraperConfig
+	return &db.SupplierModel{InnerSupplier: db.InnerSupplier{
+		ID:             id,
+		Name:           "Fake MitraHiggs",
+		Code:           "MH_OFFICIAL",
+		Type:           "mitrahiggs",
+		Username:       &username,
+		Password:       &password,
+		BaseURL:        &baseURL,
+		Status:         true,
+		MaxConcurrency: 1,
+		ExecutionMode:  mode,
+		ScraperConfig:  &config,
+		UpdatedAt:      time.Now(),
+	}}
+}
+
+func (e *env) login(ctx context.Context) (driver.Session, error) {
+	drv, err := e.drivers.Get(e.supplier)
+	if err != nil {
+		return nil, err
+	}
+	return drv.Login(ctx)
+}
+
+func (e *env) order(qty int, player string) driver.OrderRequest {
+	return driver.OrderRequest{BuyerUID: player, ProductID: productID, Quantity: qty, PaymentCode: paymentCode}
+}
+
+func scenarioOrderSuccess(ctx context.Context, e *env) error {
+	session, err := e.login(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	var sessionErr error
+	defer func() { session.Release(sessionErr) }()
+
+	// Nama player dibaca dari modal konfirmasi (fake: "Player <id>")
+	name, err := session.ValidateBuyer(ctx, validPlayer, productID)
+	if err != nil {
+		sessionErr = err
+		return fmt.Errorf("validate buyer: %w", err)
+	}
+	if want := "Player " + validPlayer; name != want {
+		return fmt.Errorf("nama player = %q, harus %q", name, want)
+	}
+
+	var recorded []driver.Unit
+	req := e.order(2, validPlayer)
+	req.OnUnit = func(u driver.Unit) { recorded = append(recorded, u) }
+
+	units, err := session.PlaceOrder(ctx, req)
+	if err != nil {
+		sessionErr = err
+		return fmt.Errorf("place order: %w", err)
+	}
+
+	orders := e.fake.Orders()
+	if len(units) != 2 || len(recorded) != 2 || len(orders) != 2 {
+		return fmt.Errorf("unit: %d, onUnit: %d, order di fake: %d (harus 2)", len(units), len(recorded), len(orders))
+	}
+	for i, u := range units {
+		if u.SupplierRef != orders[i].ID || u.PaymentURL != e.fake.PaymentURL(orders[i].ID) {
+			return fmt.Errorf("unit %d tidak cocok dengan order fake: %+v vs %s", i+1, u, orders[i].ID)
+		}
+		if orders[i].ItemID != productID || orders[i].Channel != paymentCode || orders[i].PlayerID != validPlayer {
+			return fmt.Errorf("order fake %d tidak sesuai request: %+v", i+1, orders[i])
+		}
+	}
+
+	// Status pembayaran: menunggu -> dibayar (delivered) / kedaluwarsa
+	expect := func(u driver.Unit, want string) error {
+		got, err := session.CheckStatus(ctx, u)
+		if err != nil {
+			return fmt.Errorf("check status %s: %w", u.SupplierRef, err)
+		}
+		if got != want {
+			return fmt.Errorf("status %s = %s, harus %s", u.SupplierRef, got, want)
+		}
+		return nil
+	}
+	if err := expect(units[0], driver.PaymentAwaiting); err != nil {
+		return err
+	}
+	e.fake.MarkPaid(units[0].SupplierRef)
+	e.fake.Expire(units[1].SupplierRef)
+	if err := expect(units[0], driver.PaymentDelivered); err != nil {
+		return err
+	}
+	return expect(units[1], driver.PaymentExpired)
+}
+
+func scenarioInvalidPlayer(ctx context.Context, e *env) error {
+	session, err := e.login(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	if _, err := session.ValidateBuyer(ctx, invalidPlayer, productID); !errors.Is(err, driver.ErrInvalidBuyer) {
+		session.Release(err)
+		return fmt.Errorf("validate buyer harus ErrInvalidBuyer, dapat: %v", err)
+	}
+
+	_, err = session.PlaceOrder(ctx, e.order(1, invalidPlayer))
+	session.Release(err)
+	if !errors.Is(err, driver.ErrInvalidBuyer) {
+		return fmt.Errorf("place order harus ErrInvalidBuyer, dapat: %v", err)
+	}
+	if n := len(e.fake.Orders()); n != 0 {
+		return fmt.Errorf("tidak boleh ada order di fake, ada %d", n)
+	}
+	return nil
+}
+
+func scenarioLoginRejected(ctx context.Context, e *env) error {
+	e.fake.Inject(fakemitrahiggs.FaultLoginRejected, 1)
+
+	session, err := e.login(ctx)
+	if err == nil {
+		session.Release(nil)
+		return errors.New("login harus gagal")
+	}
+	if !errors.Is(err, driver.ErrLoginRejected) {
+		return fmt.Errorf("harus ErrLoginRejected, dapat: %v", err)
+	}
+	return e.expectArtifact(err)
+}
+
+func scenarioProductMissing(ctx context.Context, e *env) error {
+	// Fault dipakai saat redirect login ke halaman trade
+	e.fake.Inject(fakemitrahiggs.FaultProductMissing, 1)
+
+	session, err := e.login(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	_, err = session.PlaceOrder(ctx, e.order(1, validPlayer))
+	session.Release(err)
+	if err == nil {
+		return errors.New("place order harus gagal")
+	}
+	if errors.Is(err, driver.ErrPurchaseUncertain) || errors.Is(err, driver.ErrInvalidBuyer) {
+		return fmt.Errorf("error salah klasifikasi: %v", err)
+	}
+	return e.expectArtifact(err)
+}
+
+func scenarioPopupBlocked(ctx context.Context, e *env) error {
+	e.fake.Inject(fakemitrahiggs.FaultPopupBlocked, 1)
+
+	session, err := e.login(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	units, err := session.PlaceOrder(ctx, e.order(1, validPlayer))
+	session.Release(err)
+	if !errors.Is(err, driver.ErrPurchaseUncertain) {
+		return fmt.Errorf("harus ErrPurchaseUncertain, dapat: %v", err)
+	}
+	if len(units) != 0 || len(e.fake.Orders()) != 1 {
+		return fmt.Errorf("unit: %d (harus 0), order di fake: %d (harus 1)", len(units), len(e.fake.Orders()))
+	}
+	return e.expectArtifact(err)
+}
+
+func scenarioSessionExpired(ctx context.Context, e *env) error {
+	session, err := e.login(ctx)
+	if err != nil {
+		return fmt.Errorf("login pertama: %w", err)
+	}
+	session.Release(nil) // Sesi kembali ke pool
+
+	e.fake.ExpireSessions()
+
+	session, err = e.login(ctx)
+	if err != nil {
+		return fmt.Errorf("login ulang: %w", err)
+	}
+	units, err := session.PlaceOrder(ctx, e.order(1, validPlayer))
+	session.Release(err)
+	if err != nil {
+		return fmt.Errorf("place order setelah login ulang: %w", err)
+	}
+	if len(units) != 1 {
+		return fmt.Errorf("unit: %d (harus 1)", len(units))
+	}
+	return nil
+}
+
+func scenarioHTTPSellCard(ctx context.Context, e *env) error {
+	// Cookie belum ada: driver http login lewat browser lalu memanggil sellCard
+	session, err := e.login(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	defer session.Release(nil)
+
+	if _, err := session.ValidateBuyer(ctx, invalidPlayer, productID); !errors.Is(err, driver.ErrInvalidBuyer) {
+		return fmt.Errorf("queryBuyer harus ErrInvalidBuyer, dapat: %v", err)
+	}
+
+	units, err := session.PlaceOrder(ctx, e.order(3, validPlayer))
+	if err != nil {
+		return fmt.Errorf("sellCard: %w", err)
+	}
+	orders := e.fake.Orders()
+	if len(units) != 3 || len(orders) != 3 || orders[0].Status != fakemitrahiggs.StatusSent {
+		return fmt.Errorf("unit: %d, order di fake: %d (harus 3 & terkirim)", len(units), len(orders))
+	}
+
+	e.fake.Inject(fakemitrahiggs.FaultSellCardError, 1)
+	units, err = session.PlaceOrder(ctx, e.order(2, validPlayer))
+	var apiErr *scraper.APIError
+	if !errors.As(err, &apiErr) || len(units) != 0 {
+		return fmt.Errorf("sellCard harus gagal dengan APIError di unit pertama, dapat: %v (unit %d)", err, len(units))
+	}
+	return nil
+}
+
+func (e *env) expectArtifact(err error) error {
+	path := driver.ArtifactPath(err)
+	if path == "" {
+		return fmt.Errorf("artefak kegagalan tidak tersimpan: %v", err)
+	}
+	e.t.Logf("📸 artefak: %s/%s", os.Getenv("SCRAPER_ARTIFACT_DIR"), path)
+	return nil
+}
+
+// ==========================================
+// JALUR WORKER PENUH (DATABASE + REDIS)
+// ==========================================
+
+func TestWorker(t *testing.T) {
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Skip("DATABASE_URL kosong, jalur worker dilewati")
+	}
+	if err := runWorkerScenario(t, newRedis(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runWorkerScenario(t *testing.T, redisClient *redis.Client) error {
+	ctx := context.Background()
+
+	client := db.NewClient()
+	if err := client.Prisma.Connect(); err != nil {
+		return fmt.Errorf("prisma: %w", err)
+	}
+	defer client.Prisma.Disconnect()
+
+	username := fmt.Sprintf("e2e-worker-%d", time.Now().Unix())
+	fake := fakemitrahiggs.New(fakemitrahiggs.Options{Username: username, Password: "rahasia"})
+	defer fake.Close()
+	defer redisClient.Del(ctx, "mitrahiggs:cookies:"+username)
+
+	t.Logf("jalur worker (fake: %s)", fake.URL)
+
+	// A. Fixture: supplier -> produk supplier -> produk internal (resep 2x)
+	supplier, err := client.Supplier.CreateOne(
+		db.Supplier.Name.Set("E2E Fake MitraHiggs"),
+		db.Supplier.Type.Set("mitrahiggs"),
+		db.Supplier.Code.Set("E2E_"+username),
+		db.Supplier.BaseURL.Set(fake.URL),
+		db.Supplier.Username.Set(username),
+		db.Supplier.Password.Set("rahasia"),
+		db.Supplier.ScraperConfig.Set(scraperConfig),
+	).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("buat supplier: %w", err)
+	}
+	// Supplier dinonaktifkan di akhir agar tidak dilayani worker server
+	defer client.Supplier.FindUnique(db.Supplier.ID.Equals(supplier.ID)).Update(db.Supplier.Status.Set(false)).Exec(ctx)
+
+	supplierProduct, err := client.SupplierProduct.CreateOne(
+		db.SupplierProduct.SupplierProductID.Set(productID),
+		db.SupplierProduct.Name.Set("E2E Koin 1M"),
+		db.SupplierProduct.Denom.Set(1000000),
+		db.SupplierProduct.CostPrice.Set(1000),
+		db.SupplierProduct.Supplier.Link(db.Supplier.ID.Equals(supplier.ID)),
+	).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("buat supplier product: %w", err)
+	}
+
+	product, err := client.Product.CreateOne(
+		db.Product.Name.Set("E2E Koin 2M"),
+		db.Product.Denom.Set(2000000),
+		db.Product.Price.Set(3000),
+		db.Product.Qty.Set(1),
+		db.Product.Supplier.Link(db.Supplier.ID.Equals(supplier.ID)),
+	).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("buat product: %w", err)
+	}
+
+	_, err = client.ProductRecipe.CreateOne(
+		db.ProductRecipe.Quantity.Set(2),
+		db.ProductRecipe.Product.Link(db.Product.ID.Equals(product.ID)),
+		db.ProductRecipe.SupplierProduct.Link(db.SupplierProduct.ID.Equals(supplierProduct.ID)),
+	).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("buat resep: %w", err)
+	}
+
+	// B. Worker pool seperti di main.go
+	queue := services.NewOrderQueue(redisClient)
+	orderService := services.NewOrderService(client, queue)
+	drivers := driver.NewManager(redisClient)
+	pool := worker.StartWorker(client, redisClient, queue, drivers)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		pool.Shutdown(shutdownCtx)
+		drivers.Close()
+	}()
+
+	placeOrder := func(player string) (string, error) {
+		internal, err := client.InternalOrder.CreateOne(
+			db.InternalOrder.BuyerUID.Set(player),
+			db.InternalOrder.Quantity.Set(1),
+			db.InternalOrder.Product.Link(db.Product.ID.Equals(product.ID)),
+		).Exec(ctx)
+		if err != nil {
+			return "", err
+		}
+		orderstate.New(client).Created(ctx, orderstate.TypeInternalOrder, internal.ID, orderstate.Pending, "system:e2e")
+
+		so, err := orderService.ProcessInternalOrder(ctx, internal.ID, supplier.ID)
+		if err != nil {
+			return "", err
+		}
+		return so.ID, nil
+	}
+
+	// C. Order sukses: 2 unit -> awaiting_payment dengan 2 URL pembayaran
+	okOrderID, err := placeOrder(validPlayer)
+	if err != nil {
+		return fmt.Errorf("order sukses: %w", err)
+	}
+	if err := waitStatus(ctx, client, okOrderID, 3*time.Minute, driver.PaymentAwaiting); err != nil {
+		return err
+	}
+	txs, err := services.LoadTransactions(ctx, client, okOrderID)
+	if err != nil || len(txs) != 2 {
+		return fmt.Errorf("ledger transaksi: %d (harus 2), err: %v", len(txs), err)
+	}
+
+	// D. Player tidak valid: gagal permanen tanpa retry
+	badOrderID, err := placeOrder(invalidPlayer)
+	if err != nil {
+		return fmt.Errorf("order player salah: %w", err)
+	}
+	if err := waitStatus(ctx, client, badOrderID, 3*time.Minute, orderstate.Failed); err != nil {
+		return err
+	}
+
+	// E. Pembayaran: semua unit dibayar di fake -> payment poller -> delivered
+	for _, tx := range txs {
+		fake.MarkPaid(tx.SupplierRef)
+	}
+	if err := waitStatus(ctx, client, okOrderID, 5*time.Minute, driver.PaymentDelivered); err != nil {
+		return fmt.Errorf("%w (PAYMENT_POLL_INTERVAL terlalu lama?)", err)
+	}
+	return nil
+}
+
+// waitStatus menunggu supplier order mencapai status want; status akhir lain = gagal
+func waitStatus(ctx context.Context, client *db.PrismaClient, orderID string, timeout time.Duration, want string) error {
+	deadline := time.Now().Add(timeout)
+	last := ""
+	for time.Now().Before(deadline) {
+		order, err := client.SupplierOrder.FindUnique(db.SupplierOrder.ID.Equals(orderID)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		last = order.Status
+		if last == want {
+			return nil
+		}
+		if !orderstate.CanTransition(last, want) && last != orderstate.Processing && last != orderstate.Pending {
+			lastErr, _ := order.LastError()
+			return fmt.Errorf("order %s berhenti di %s (last_error: %s)", orderID, last, lastErr)
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("order %s tidak mencapai %s dalam %s (terakhir: %s)", orderID, want, timeout, last)
+}
@@ -0,0 +1,463 @@
+// Package fakemitrahiggs adalah tiruan offline web mitrahiggs.com untuk tes
+// end-to-end scraper (Login, ValidateBuyer, PlaceOrder, CheckPayment) dan mode
+// HTTP (trade/queryBuyer, trade/sellCard) tanpa menyentuh situs asli.
+//
+// Markup & JS meniru struktur situs asli sebatas yang dipakai selector default
+// scraper.MitraHiggsConfig: form login ID, toko ShopGoldcoinsInfull, alert
+// #publicTip untuk ID tidak valid, dan tab pembayaran (about:blank -> redirect).
+package fakemitrahiggs
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const sessionCookie = "MHSESSID"
+
+// Fault adalah kegagalan yang bisa disuntikkan ke fake
+type Fault string
+
+const (
+	FaultLoginRejected  Fault = "login_rejected"  // Login menampilkan .alert-danger
+	FaultLoginTimeout   Fault = "login_timeout"   // Login tidak pernah redirect ke /trade/index
+	FaultSessionExpired Fault = "session_expired" // Sesi dibuang, request berikutnya diarahkan ke login
+	FaultProductMissing Fault = "product_missing" // Halaman trade tanpa daftar produk
+	FaultPopupBlocked   Fault = "popup_blocked"   // Order tercatat tapi tab pembayaran tidak terbuka
+	FaultSellCardError  Fault = "sellcard_error"  // trade/sellCard mengembalikan code != 0
+)
+
+// Status order di fake (dibaca lewat halaman pembayaran)
+const (
+	StatusPending = "pending"
+	StatusPaid    = "paid"
+	StatusExpired = "expired"
+	StatusSent    = "sent" // sellCard: item langsung terkirim
+)
+
+// Item adalah produk koin di toko (li ShopGoldcoinsInfull.chooseItem)
+type Item struct {
+	ID   string
+	Name string
+}
+
+// Order adalah satu pembelian yang diterima fake
+type Order struct {
+	ID        string
+	PlayerID  string
+	ItemID    string
+	Channel   string
+	Status    string
+	CreatedAt time.Time
+}
+
+type Options struct {
+	Username string
+	Password string
+	Items    []Item   // Default: item 1 & 6-12
+	Channels []string // Default: 40 (QRIS), 14 (DANA), 22 (ShopeePay), 13 (OVO)
+
+	// Player yang dianggap tidak ada (code 3001). Default: "000000".
+	InvalidPlayers []string
+}
+
+// Server adalah fake MitraHiggs yang berjalan di httptest.Server
+type Server struct {
+	*httptest.Server
+
+	opts Options
+
+	mu       sync.Mutex
+	sessions map[string]bool
+	orders   map[string]*Order
+	faults   map[Fault]int
+	seq      int
+}
+
+// New menjalankan fake di port lokal acak. Panggil Close setelah selesai.
+func New(opts Options) *Server {
+	if opts.Username == "" {
+		opts.Username = "fakeuser"
+	}
+	if opts.Password == "" {
+		opts.Password = "fakepass"
+	}
+	if len(opts.Items) == 0 {
+		opts.Items = []Item{{"1", "60M"}, {"6", "1M"}, {"7", "30M"}, {"8", "60M"}, {"9", "200M"}, {"10", "400M"}, {"11", "1B"}, {"12", "2B"}}
+	}
+	if len(opts.Channels) == 0 {
+		opts.Channels = []string{"40", "14", "22", "13"}
+	}
+	if opts.InvalidPlayers == nil {
+		opts.InvalidPlayers = []string{"000000"}
+	}
+
+	s := &Server{
+		opts:     opts,
+		sessions: make(map[string]bool),
+		orders:   make(map[string]*Order),
+		faults:   make(map[Fault]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleLoginPage)
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/trade/index", s.requireSession(s.handleTrade))
+	mux.HandleFunc("/trade/queryBuyer", s.requireSession(s.handleQueryBuyer))
+	mux.HandleFunc("/trade/buy", s.requireSession(s.handleBuy))
+	mux.HandleFunc("/trade/sellCard", s.requireSession(s.handleSellCard))
+	mux.HandleFunc("/pay/index", s.handlePayment)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Inject mengaktifkan fault untuk n kejadian berikutnya (n <= 0 = nonaktif)
+func (s *Server) Inject(f Fault, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n <= 0 {
+		delete(s.faults, f)
+		return
+	}
+	s.faults[f] = n
+}
+
+// ExpireSessions membuang semua sesi login (seperti sesi expired di situs asli)
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]bool)
+}
+
+// Orders mengembalikan salinan semua order yang diterima (urut waktu)
+func (s *Server) Orders() []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Order, 0, len(s.orders))
+	for i := 1; i <= s.seq; i++ {
+		if o, ok := s.orders[orderID(i)]; ok {
+			list = append(list, *o)
+		}
+	}
+	return list
+}
+
+// MarkPaid menandai order sudah dibayar (halaman pembayaran menampilkan sukses)
+func (s *Server) MarkPaid(id string) bool { return s.setStatus(id, StatusPaid) }
+
+// Expire menandai batas waktu pembayaran order habis
+func (s *Server) Expire(id string) bool { return s.setStatus(id, StatusExpired) }
+
+// PaymentURL adalah URL tab pembayaran untuk order
+func (s *Server) PaymentURL(id string) string {
+	return s.URL + "/pay/index?orderId=" + url.QueryEscape(id)
+}
+
+func (s *Server) setStatus(id, status string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[id]
+	if ok {
+		o.Status = status
+	}
+	return ok
+}
+
+// take mengonsumsi satu kejadian fault, return true jika fault aktif
+func (s *Server) take(f Fault) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.faults[f] <= 0 {
+		return false
+	}
+	s.faults[f]--
+	return true
+}
+
+func (s *Server) newOrder(playerID, itemID, channel, status string) *Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	o := &Order{
+		ID:        orderID(s.seq),
+		PlayerID:  playerID,
+		ItemID:    itemID,
+		Channel:   channel,
+		Status:    status,
+		CreatedAt: time.Now(),
+	}
+	s.orders[o.ID] = o
+	return o
+}
+
+func orderID(seq int) string {
+	return fmt.Sprintf("MH%08d", seq)
+}
+
+func (s *Server) validPlayer(playerID string) bool {
+	if playerID == "" {
+		return false
+	}
+	for _, id := range s.opts.InvalidPlayers {
+		if id == playerID {
+			return false
+		}
+	}
+	return true
+}
+
+// requireSession meniru situs asli: tanpa sesi valid, request diarahkan ke halaman login
+func (s *Server) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.take(FaultSessionExpired) {
+			s.ExpireSessions()
+		}
+
+		ck, err := r.Cookie(sessionCookie)
+		s.mu.Lock()
+		ok := err == nil && s.sessions[ck.Value]
+		s.mu.Unlock()
+
+		if !ok {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, loginPage, map[string]string{"Error": r.URL.Query().Get("error")})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	if s.take(FaultLoginTimeout) {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	user, pass := r.FormValue("username"), r.FormValue("password")
+	if user != s.opts.Username || pass != s.opts.Password || s.take(FaultLoginRejected) {
+		http.Redirect(w, r, "/?error="+url.QueryEscape("ID atau password salah"), http.StatusFound)
+		return
+	}
+
+	token := strconv.FormatInt(time.Now().UnixNano(), 36)
+	s.mu.Lock()
+	s.sessions[token] = true
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true})
+	http.Redirect(w, r, "/trade/index", http.StatusFound)
+}
+
+func (s *Server) handleTrade(w http.ResponseWriter, r *http.Request) {
+	// ID item ditulis apa adanya di onclick (chooseItem(7, this)) seperti situs
+	// asli agar selector `chooseItem(%s` cocok; tanpa template.JS, html/template
+	// akan mengutipnya menjadi chooseItem("7", this)
+	type itemView struct {
+		ID   template.JS
+		Name string
+	}
+
+	var items []itemView
+	if !s.take(FaultProductMissing) {
+		for _, it := range s.opts.Items {
+			items = append(items, itemView{ID: template.JS(it.ID), Name: it.Name})
+		}
+	}
+	render(w, tradePage, map[string]interface{}{
+		"Items":    items,
+		"Channels": s.opts.Channels,
+	})
+}
+
+func (s *Server) handleQueryBuyer(w http.ResponseWriter, r *http.Request) {
+	playerID := r.FormValue("buyerId")
+	if !s.validPlayer(playerID) {
+		writeAPI(w, 3001, "User tidak ada", nil)
+		return
+	}
+	uid, _ := strconv.ParseInt(playerID, 10, 64)
+	writeAPI(w, 0, "", map[string]interface{}{"nickName": "Player " + playerID, "userId": uid})
+}
+
+// handleBuy dipanggil tombol Kirim (ShopGoldcoinsInfull.buyItem). Dengan
+// FaultPopupBlocked order tetap tercatat tapi tab pembayaran tidak dibuka,
+// meniru kasus "pembelian mungkin sudah terjadi" di situs asli.
+func (s *Server) handleBuy(w http.ResponseWriter, r *http.Request) {
+	playerID := r.FormValue("buyerId")
+	if !s.validPlayer(playerID) {
+		writeAPI(w, 3001, "User tidak ada", nil)
+		return
+	}
+
+	o := s.newOrder(playerID, r.FormValue("itemId"), r.FormValue("channel"), StatusPending)
+	writeAPI(w, 0, "", map[string]interface{}{
+		"payUrl": s.PaymentURL(o.ID),
+		"popup":  !s.take(FaultPopupBlocked),
+	})
+}
+
+func (s *Server) handleSellCard(w http.ResponseWriter, r *http.Request) {
+	playerID := r.FormValue("buyerId")
+	if !s.validPlayer(playerID) {
+		writeAPI(w, 3001, "User tidak ada", nil)
+		return
+	}
+	if s.take(FaultSellCardError) {
+		writeAPI(w, 5000, "Stok item habis", nil)
+		return
+	}
+
+	s.newOrder(playerID, r.FormValue("itemId"), "", StatusSent)
+	writeAPI(w, 0, "", nil)
+}
+
+func (s *Server) handlePayment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	o, ok := s.orders[r.URL.Query().Get("orderId")]
+	status := ""
+	if ok {
+		status = o.Status
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var message string
+	switch status {
+	case StatusPaid, StatusSent:
+		message = "Pembayaran berhasil, koin sudah dikirim"
+	case StatusExpired:
+		message = "Transaksi kedaluwarsa"
+	default:
+		message = "Menunggu pembayaran, silakan scan QR di bawah"
+	}
+	render(w, paymentPage, map[string]string{"OrderID": o.ID, "Message": message})
+}
+
+func writeAPI(w http.ResponseWriter, code int, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "errMsg": msg, "data": data})
+}
+
+func render(w http.ResponseWriter, tpl *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><title>Mitra Higgs</title></head>
+<body>
+  <div class="login-tabs">
+    <span name="index-html-id-login" onclick="document.getElementById('loginForm').style.display='block'">Login dengan ID</span>
+    <span class="login-text" onclick="document.getElementById('loginForm').style.display='block'">Masuk</span>
+  </div>
+  {{if .Error}}<div class="alert-danger">{{.Error}}</div>{{end}}
+  <form id="loginForm" method="post" action="/login" style="display:none">
+    <input type="text" name="username" placeholder="ID">
+    <input type="password" name="password" placeholder="Password">
+    <a id="pwdLoginButton" href="javascript:void(0)" onclick="document.getElementById('loginForm').submit()">Login</a>
+  </form>
+</body></html>`))
+
+var tradePage = template.Must(template.New("trade").Parse(`<!DOCTYPE html>
+<html><head><title>Mitra Higgs - Toko Koin</title></head>
+<body>
+  <div id="thickdivInvitation" style="position:fixed;top:0;left:0;right:0;bottom:0;background:#0008">Undangan event</div>
+
+  <ul id="items">
+    {{range .Items}}<li onclick="ShopGoldcoinsInfull.chooseItem({{.ID}}, this)">{{.Name}}</li>
+    {{end}}
+  </ul>
+  <input type="text" id="userId" placeholder="ID Player">
+  <ul id="channels">
+    {{range .Channels}}<li onclick="ShopGoldcoinsInfull.chooseInfull(this)" infullchannel="{{.}}">Channel {{.}}</li>
+    {{end}}
+  </ul>
+  <a href="javascript:void(0)" onclick="ShopGoldcoinsInfull.queryBuyer();">Top Up</a>
+
+  <div id="confirmModal" style="display:none">
+    <p id="queryBuyerName"></p>
+    <a href="javascript:void(0)" onclick="ShopGoldcoinsInfull.buyItem();">Kirim</a>
+  </div>
+  <div id="publicTip" style="display:none"><span id="publicTxt"></span></div>
+
+<script>
+function hideInvitation() {
+  document.getElementById('thickdivInvitation').style.display = 'none';
+}
+var Common = {
+  close: function() {
+    document.getElementById('publicTip').style.display = 'none';
+    document.getElementById('confirmModal').style.display = 'none';
+  }
+};
+function post(path, data) {
+  var xhr = new XMLHttpRequest();
+  xhr.open('POST', path, false);
+  xhr.setRequestHeader('Content-Type', 'application/x-www-form-urlencoded');
+  xhr.send(new URLSearchParams(data).toString());
+  return JSON.parse(xhr.responseText);
+}
+function tip(msg) {
+  document.getElementById('publicTxt').innerText = msg;
+  document.getElementById('publicTip').style.display = 'block';
+}
+var ShopGoldcoinsInfull = {
+  item: null,
+  channel: null,
+  chooseItem: function(id) { this.item = String(id); },
+  chooseInfull: function(el) { this.channel = el.getAttribute('infullchannel'); },
+  queryBuyer: function() {
+    var res = post('/trade/queryBuyer', {buyerId: document.getElementById('userId').value});
+    if (res.code !== 0) { tip(res.errMsg); return; }
+    document.getElementById('queryBuyerName').innerText = res.data.nickName;
+    document.getElementById('confirmModal').style.display = 'block';
+  },
+  buyItem: function() {
+    var res = post('/trade/buy', {
+      itemId: this.item,
+      channel: this.channel,
+      buyerId: document.getElementById('userId').value
+    });
+    document.getElementById('confirmModal').style.display = 'none';
+    if (res.code !== 0) { tip(res.errMsg); return; }
+    if (!res.data.popup) { return; }
+    var w = window.open('about:blank');
+    setTimeout(function() { w.location.href = res.data.payUrl; }, 300);
+  }
+};
+</script>
+</body></html>`))
+
+var paymentPage = template.Must(template.New("payment").Parse(`<!DOCTYPE html>
+<html><head><title>Pembayaran {{.OrderID}}</title></head>
+<body>
+  <h1>Order {{.OrderID}}</h1>
+  <p class="status">{{.Message}}</p>
+  <p class="note">QR akan kedaluwarsa dalam 15 menit</p>
+</body></html>`))